@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+func add(args []string) int {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	name := flags.String("name", "", "Entry name (default is the file name)")
+	lastWriteTime := flags.String("time", "", "Last write time as \"2006-01-02 15:04:05\" (default is the file modification time)")
+	at := flags.Int("at", -1, "Insert at this index instead of appending")
+	null := flags.Bool("null", false, "Add a null entry instead of a file")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs add [flags] <path to AFS> <path to file>\n       afs add -null [-at <index>] <path to AFS>\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if (*null && len(positional) != 1) || (!*null && len(positional) != 2) {
+		flags.Usage()
+		return exitUsage
+	}
+
+	afsPath := positional[0]
+
+	a, err := afs.OpenFileForUpdate(afsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+	defer a.Close()
+
+	index := *at
+	if index < 0 {
+		index = len(a.Entries)
+	}
+
+	if *null {
+		err = a.InsertNullEntry(index)
+	} else {
+		filePath := positional[1]
+
+		info, statErr := os.Stat(filePath)
+		if statErr != nil {
+			fmt.Fprintln(os.Stderr, statErr)
+			return exitFailure
+		}
+
+		source, sourceErr := afs.NewFileSource(filePath)
+		if sourceErr != nil {
+			fmt.Fprintln(os.Stderr, sourceErr)
+			return exitFailure
+		}
+
+		if *name == "" {
+			*name = filepath.Base(filePath)
+		}
+
+		timestamp := afs.NewTimestamp(info.ModTime())
+		if *lastWriteTime != "" {
+			if timestamp, err = afs.ParseTimestamp(*lastWriteTime); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return exitUsage
+			}
+		}
+
+		err = a.InsertEntry(index, source, *name, timestamp)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	if err := a.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	entry := a.Entries[index]
+	if entry.IsNull {
+		fmt.Printf("Added null entry %d\n", index)
+	} else {
+		fmt.Printf("Added entry %d (%s) at 0x%X, %d bytes\n", index, entry.Name, entry.Offset, entry.Size)
+	}
+
+	return exitOk
+}
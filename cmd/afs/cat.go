@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+func cat(args []string) int {
+	flags := flag.NewFlagSet("cat", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs cat <path to AFS> <entry index or name>\n\nWrites the entry data to standard output.\n")
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 2 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	afsPath := positional[0]
+
+	a, err := afs.OpenFile(afsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+	defer a.Close()
+
+	index, err := findEntry(a, positional[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	reader, err := a.OpenEntry(index)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(os.Stdout, reader); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	return exitOk
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+func diff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	asJson := flags.Bool("json", false, "Print the differences as JSON")
+	by := flags.String("by", "name", "Match entries by \"name\" or \"index\"")
+	quick := flags.Bool("quick", false, "Compare sizes only, do not read entry data")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs diff [flags] <path to AFS> <path to AFS>\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 2 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	options := afs.DiffOptions{SkipContent: *quick}
+	switch *by {
+	case "name":
+		options.Match = afs.DiffMatchName
+	case "index":
+		options.Match = afs.DiffMatchIndex
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown match %q, use name or index\n", *by)
+		return exitUsage
+	}
+
+	archives := []*afs.Afs{}
+	for _, afsPath := range positional {
+		a, err := afs.OpenFile(afsPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+			return exitTrouble
+		}
+		defer a.Close()
+
+		archives = append(archives, a)
+	}
+
+	report, err := afs.Diff(archives[0], archives[1], options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitTrouble
+	}
+
+	if *asJson {
+		buf, err := json.MarshalIndent(report, "", "\t")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitTrouble
+		}
+		fmt.Println(string(buf))
+	} else {
+		for _, change := range report.Header {
+			fmt.Printf("header %s: %s -> %s\n", change.Field, change.Old, change.New)
+		}
+
+		for _, change := range report.Changes {
+			fmt.Println(describeChange(change))
+		}
+
+		if report.Equal() {
+			fmt.Println("Archives are identical")
+		}
+	}
+
+	if !report.Equal() {
+		return exitDifferent
+	}
+
+	return exitOk
+}
+
+func describeChange(change *afs.Change) string {
+	switch change.Kind {
+	case afs.ChangeAdded:
+		return fmt.Sprintf("+ %d %s: added, %s", change.NewIndex, change.Name, change.New)
+	case afs.ChangeRemoved:
+		return fmt.Sprintf("- %d %s: removed, was %s", change.OldIndex, change.Name, change.Old)
+	case afs.ChangeMoved:
+		return fmt.Sprintf("~ %d %s: moved to %d", change.OldIndex, change.Name, change.NewIndex)
+	case afs.ChangeNull:
+		return fmt.Sprintf("~ %d %s: %s -> %s", change.NewIndex, change.Name, change.Old, change.New)
+	case afs.ChangeContent:
+		return fmt.Sprintf("~ %d %s: content changed", change.NewIndex, change.Name)
+	default:
+		return fmt.Sprintf("~ %d %s: %s %s -> %s", change.NewIndex, change.Name, change.Kind, change.Old, change.New)
+	}
+}
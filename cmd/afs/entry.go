@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+func findEntry(a *afs.Afs, selector string) (int, error) {
+	if index, err := strconv.Atoi(selector); err == nil {
+		if index < 0 || index >= len(a.Entries) {
+			return 0, fmt.Errorf("%w: %d", afs.ErrEntryOutOfRange, index)
+		}
+		return index, nil
+	}
+
+	found := -1
+	for i, entry := range a.Entries {
+		if entry.IsNull || entry.Name != selector {
+			continue
+		}
+
+		if found >= 0 {
+			return 0, fmt.Errorf("Name %s is used by entries %d and %d, select by index", selector, found, i)
+		}
+		found = i
+	}
+
+	if found < 0 {
+		return 0, fmt.Errorf("No entry named %s", selector)
+	}
+
+	return found, nil
+}
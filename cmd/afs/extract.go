@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/anasrar/afs/internal/packer"
+	"github.com/anasrar/afs/internal/selector"
+)
+
+func extract(args []string) int {
+	flags := flag.NewFlagSet("extract", flag.ContinueOnError)
+	jobs := flags.Int("jobs", 1, "Number of entries to extract in parallel")
+	preserveLayout := flags.Bool("preserve-layout", false, "Record the original layout so pack can reproduce the archive byte for byte")
+	nameEncoding := flags.String("name-encoding", "", "Decode entry names as ascii, shift-jis or utf-8 (default detected)")
+	fullMetadata := flags.Bool("full-metadata", false, "Describe every entry in METADATA.json, entries not extracted are packed from the AFS")
+	output := ""
+	flags.StringVar(&output, "o", "", "Directory for METADATA.json and the files (default UNPACK_<name> next to the AFS)")
+	flags.StringVar(&output, "output", "", "Same as -o")
+	filesDir := flags.String("files-dir", packer.DefaultFilesDir, "Directory for the files inside the output directory")
+	exclude := stringsFlag{}
+	flags.Var(&exclude, "exclude", "Skip entries matching an index range, glob or regex, may be repeated")
+	verbose := flags.Bool("v", false, "Print every extracted entry")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs extract [flags] <path to AFS> [selector...]\n\nWrites the entries to FILES and METADATA.json in UNPACK_<name> next to the AFS, or in -o.\n\nA selector is an index range such as 10-20,35 or 100-, a name glob such as \"*.adx\"\nor a name regex prefixed with %s. Without selectors every entry is extracted.\n\nFlags:\n", selector.RegexPrefix)
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) < 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	afsPath := positional[0]
+
+	entrySelector, err := selector.New(positional[1:], exclude)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	var selectEntry func(index int, name string) bool
+	if !entrySelector.IsAll() {
+		selectEntry = entrySelector.Match
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	count := 0
+	if err := packer.Unpack(
+		ctx,
+		afsPath,
+		packer.UnpackOptions{
+			Jobs:           *jobs,
+			PreserveLayout: *preserveLayout,
+			NameEncoding:   *nameEncoding,
+			Select:         selectEntry,
+			FullMetadata:   *fullMetadata,
+			Output:         output,
+			FilesDir:       *filesDir,
+		},
+		func(total, current uint32, name string) {},
+		func(total, current uint32, name string) {
+			count += 1
+			if *verbose {
+				fmt.Printf("%d/%d %s\n", current, total, name)
+			}
+		},
+		func(message string) {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+		},
+	); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	fmt.Printf("%s: extracted %d entries\n", afsPath, count)
+
+	return exitOk
+}
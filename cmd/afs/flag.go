@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+)
+
+func exitCodeForParse(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOk
+	}
+	return exitUsage
+}
+
+// parseInterspersed parses flags that appear before, between or after the
+// positional arguments and returns the positional arguments in order.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// stringsFlag collects every value of a flag that may be repeated.
+type stringsFlag []string
+
+func (self *stringsFlag) String() string {
+	return strings.Join(*self, ",")
+}
+
+func (self *stringsFlag) Set(value string) error {
+	*self = append(*self, value)
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+type archiveInfo struct {
+	Path                string             `json:"path"`
+	FileSize            int64              `json:"file_size"`
+	Version             afs.Version        `json:"version"`
+	AttributesInfo      afs.AttributesInfo `json:"attributes_info"`
+	EntryBlockAlignment uint32             `json:"entry_block_alignment"`
+	DataAlignment       uint32             `json:"data_alignment"`
+	EndAlignment        uint32             `json:"end_alignment"`
+	PaddingFill         byte               `json:"padding_fill"`
+	NameEncoding        afs.NameEncoding   `json:"name_encoding"`
+	EntryTotal          uint32             `json:"entry_total"`
+	NullEntries         int                `json:"null_entries"`
+	EmptyEntries        int                `json:"empty_entries"`
+	DataSize            uint64             `json:"data_size"`
+}
+
+func info(args []string) int {
+	flags := flag.NewFlagSet("info", flag.ContinueOnError)
+	asJson := flags.Bool("json", false, "Print the information as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs info [flags] <path to AFS>\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	afsPath := positional[0]
+
+	stat, err := os.Stat(afsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	a, err := afs.OpenFile(afsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+	defer a.Close()
+
+	result := &archiveInfo{
+		Path:                afsPath,
+		FileSize:            stat.Size(),
+		Version:             a.Version,
+		AttributesInfo:      a.AttributesInfo,
+		EntryBlockAlignment: a.EntryBlockAlignment,
+		DataAlignment:       a.DataAlignment,
+		EndAlignment:        a.EndAlignment,
+		PaddingFill:         a.PaddingFill,
+		NameEncoding:        a.NameEncoding,
+		EntryTotal:          a.EntryTotal,
+	}
+
+	for _, entry := range a.Entries {
+		switch {
+		case entry.IsNull:
+			result.NullEntries += 1
+		case entry.IsEmpty():
+			result.EmptyEntries += 1
+		default:
+			result.DataSize += uint64(entry.Size)
+		}
+	}
+
+	if *asJson {
+		buf, err := json.MarshalIndent(result, "", "\t")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		fmt.Println(string(buf))
+		return exitOk
+	}
+
+	fmt.Printf("Path:                  %s\n", result.Path)
+	fmt.Printf("File size:             %d\n", result.FileSize)
+	fmt.Printf("Version:               %s\n", result.Version)
+	fmt.Printf("Attributes info:       %s\n", result.AttributesInfo)
+	fmt.Printf("Entry block alignment: 0x%X\n", result.EntryBlockAlignment)
+	fmt.Printf("Data alignment:        0x%X\n", result.DataAlignment)
+	fmt.Printf("End alignment:         0x%X\n", result.EndAlignment)
+	fmt.Printf("Padding fill:          0x%02X\n", result.PaddingFill)
+	fmt.Printf("Name encoding:         %s\n", result.NameEncoding)
+	fmt.Printf("Entries:               %d (%d null, %d empty)\n", result.EntryTotal, result.NullEntries, result.EmptyEntries)
+	fmt.Printf("Data size:             %d\n", result.DataSize)
+
+	return exitOk
+}
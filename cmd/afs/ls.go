@@ -0,0 +1,220 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+type listedEntry struct {
+	Index         int           `json:"index"`
+	Name          string        `json:"name"`
+	Offset        uint32        `json:"offset"`
+	Size          uint32        `json:"size"`
+	PaddedSize    uint32        `json:"padded_size"`
+	LastWriteTime afs.Timestamp `json:"last_write_time"`
+	CustomData    uint32        `json:"custom_data"`
+	IsNull        bool          `json:"is_null"`
+	IsEmpty       bool          `json:"is_empty"`
+	Type          string        `json:"type"`
+}
+
+var listColumns = []string{"index", "name", "offset", "size", "padded_size", "last_write_time", "custom_data", "null", "type"}
+
+func (self *listedEntry) fields(hex bool) []string {
+	number := func(value uint32) string {
+		if hex {
+			return fmt.Sprintf("0x%X", value)
+		}
+		return strconv.FormatUint(uint64(value), 10)
+	}
+
+	return []string{
+		strconv.Itoa(self.Index),
+		self.Name,
+		number(self.Offset),
+		strconv.FormatUint(uint64(self.Size), 10),
+		strconv.FormatUint(uint64(self.PaddedSize), 10),
+		self.LastWriteTime.String(),
+		number(self.CustomData),
+		strconv.FormatBool(self.IsNull),
+		self.Type,
+	}
+}
+
+func ls(args []string) int {
+	flags := flag.NewFlagSet("ls", flag.ContinueOnError)
+	format := flags.String("format", "table", "Output as \"table\", \"json\" or \"csv\"")
+	asJson := flags.Bool("json", false, "Shorthand for -format json")
+	sortBy := flags.String("sort", "index", "Sort by index, name, offset, size, time, custom_data or type")
+	reverse := flags.Bool("reverse", false, "Reverse the sort order")
+	name := flags.String("name", "", "Only list entries whose name matches a glob such as \"*.adx\"")
+	fileType := flags.String("type", "", "Only list entries of a detected type such as adx, or \"unknown\"")
+	minSize := flags.Int64("min-size", -1, "Only list entries of at least this many bytes")
+	maxSize := flags.Int64("max-size", -1, "Only list entries of at most this many bytes")
+	hideNull := flags.Bool("hide-null", false, "Do not list null entries")
+	onlyNull := flags.Bool("null", false, "Only list null entries")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs ls [flags] <path to AFS>\n\nColumns: %s\n\nFlags:\n", strings.Join(listColumns, ", "))
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	if *asJson {
+		*format = "json"
+	}
+
+	if !slices.Contains([]string{"table", "json", "csv"}, *format) {
+		fmt.Fprintf(os.Stderr, "Unknown format %q, use table, json or csv\n", *format)
+		return exitUsage
+	}
+
+	compare, found := listSorts[*sortBy]
+	if !found {
+		fmt.Fprintf(os.Stderr, "Unknown sort %q, use index, name, offset, size, time, custom_data or type\n", *sortBy)
+		return exitUsage
+	}
+
+	if *name != "" {
+		if _, err := path.Match(*name, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid name pattern %q: %s\n", *name, err)
+			return exitUsage
+		}
+	}
+
+	afsPath := positional[0]
+
+	a, err := afs.OpenFile(afsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+	defer a.Close()
+
+	entries := []*listedEntry{}
+	for i, entry := range a.Entries {
+		listed := &listedEntry{
+			Index:         i,
+			Name:          entry.Name,
+			LastWriteTime: entry.LastWriteTime,
+			IsNull:        entry.IsNull,
+			IsEmpty:       entry.IsEmpty(),
+		}
+
+		if !entry.IsNull {
+			listed.Offset = entry.Offset
+			listed.Size = entry.Size
+			listed.PaddedSize = padSize(entry.Size, a.DataAlignment)
+			listed.CustomData = entry.CustomData
+
+			if listed.Type, err = entry.FileType(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: entry %d (%s): %s\n", afsPath, i, entry.Name, err)
+				return exitFailure
+			}
+		}
+
+		switch {
+		case *hideNull && listed.IsNull, *onlyNull && !listed.IsNull:
+			continue
+		case *minSize >= 0 && int64(listed.Size) < *minSize, *maxSize >= 0 && int64(listed.Size) > *maxSize:
+			continue
+		case *fileType == "unknown" && listed.Type != "", *fileType != "" && *fileType != "unknown" && listed.Type != *fileType:
+			continue
+		}
+
+		if *name != "" {
+			if matched, _ := path.Match(*name, listed.Name); !matched {
+				continue
+			}
+		}
+
+		entries = append(entries, listed)
+	}
+
+	slices.SortStableFunc(entries, func(a, b *listedEntry) int {
+		if *reverse {
+			return cmp.Or(compare(b, a), cmp.Compare(b.Index, a.Index))
+		}
+		return cmp.Or(compare(a, b), cmp.Compare(a.Index, b.Index))
+	})
+
+	switch *format {
+	case "json":
+		buf, err := json.MarshalIndent(entries, "", "\t")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		fmt.Println(string(buf))
+	case "csv":
+		writer := csv.NewWriter(os.Stdout)
+		writer.Write(listColumns)
+		for _, entry := range entries {
+			writer.Write(entry.fields(false))
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+	default:
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(writer, strings.ToUpper(strings.Join(listColumns, "\t"))+"\t")
+		for _, entry := range entries {
+			fmt.Fprintln(writer, strings.Join(entry.fields(true), "\t")+"\t")
+		}
+		writer.Flush()
+	}
+
+	return exitOk
+}
+
+var listSorts = map[string]func(a, b *listedEntry) int{
+	"index": func(a, b *listedEntry) int {
+		return cmp.Compare(a.Index, b.Index)
+	},
+	"name": func(a, b *listedEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	},
+	"offset": func(a, b *listedEntry) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	},
+	"size": func(a, b *listedEntry) int {
+		return cmp.Compare(a.Size, b.Size)
+	},
+	"time": func(a, b *listedEntry) int {
+		return strings.Compare(a.LastWriteTime.String(), b.LastWriteTime.String())
+	},
+	"custom_data": func(a, b *listedEntry) int {
+		return cmp.Compare(a.CustomData, b.CustomData)
+	},
+	"type": func(a, b *listedEntry) int {
+		return strings.Compare(a.Type, b.Type)
+	},
+}
+
+func padSize(size uint32, alignment uint32) uint32 {
+	if alignment == 0 || size%alignment == 0 {
+		return size
+	}
+	return size + (alignment - size%alignment)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var GitCommitHash = "Dev Mode"
+
+const (
+	exitOk      = 0
+	exitFailure = 1
+	exitUsage   = 2
+	// afs diff follows diff and cmp, 1 means the archives differ and 2 that
+	// they could not be compared.
+	exitDifferent = 1
+	exitTrouble   = 2
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) int
+}
+
+var commands = []*command{
+	{"info", "Show the header and layout of an AFS file", info},
+	{"ls", "List the entries of an AFS file", ls},
+	{"extract", "Extract an AFS file with METADATA.json for repacking", extract},
+	{"pack", "Pack an AFS file from METADATA.json", pack},
+	{"verify", "Check an AFS file for structural problems", verify},
+	{"cat", "Write the data of one entry to standard output", cat},
+	{"replace", "Replace the data of one entry in place", replace},
+	{"add", "Append or insert an entry in an AFS file", add},
+	{"rm", "Remove an entry or turn it into a null entry", rm},
+	{"move", "Move an entry to another index", move},
+	{"diff", "Compare two AFS files entry by entry", diff},
+	{"patch", "Create or apply a patch between two AFS files", patch},
+	{"overlay", "Write a copy of an AFS file with entries replaced from a directory", overlay},
+	{"stack", "Apply mods listed in a mods.json manifest in priority order", stack},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "AFS tool (build %s)\n\n", GitCommitHash)
+	fmt.Fprintf(os.Stderr, "Usage: afs <command> [flags] [arguments]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun \"afs <command> --help\" for command flags.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		os.Exit(exitOk)
+	}
+
+	for _, c := range commands {
+		if c.name == name {
+			os.Exit(c.run(os.Args[2:]))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	usage()
+	os.Exit(exitUsage)
+}
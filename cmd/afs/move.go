@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+func move(args []string) int {
+	flags := flag.NewFlagSet("move", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs move <path to AFS> <entry index or name> <new index>\n")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 3 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	to, err := strconv.Atoi(positional[2])
+	if err != nil {
+		flags.Usage()
+		return exitUsage
+	}
+
+	afsPath := positional[0]
+
+	a, err := afs.OpenFileForUpdate(afsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+	defer a.Close()
+
+	from, err := findEntry(a, positional[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	if err := a.MoveEntry(from, to); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	if err := a.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	fmt.Printf("Moved entry %d (%s) to %d\n", from, a.Entries[to].Name, to)
+
+	return exitOk
+}
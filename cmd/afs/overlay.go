@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+func overlay(args []string) int {
+	flags := flag.NewFlagSet("overlay", flag.ContinueOnError)
+	output := flags.String("o", "", "Path to the output AFS (required)")
+	jobs := flags.Int("jobs", 1, "Number of entries to pack in parallel")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs overlay <base AFS> <mods directory> -o <output AFS>\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 2 || *output == "" {
+		flags.Usage()
+		return exitUsage
+	}
+
+	basePath := positional[0]
+	modsPath := positional[1]
+
+	if sameFile(basePath, *output) {
+		fmt.Fprintf(os.Stderr, "Output %s would overwrite the base archive\n", *output)
+		return exitUsage
+	}
+
+	a, err := afs.OpenFile(basePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", basePath, err)
+		return exitFailure
+	}
+	defer a.Close()
+
+	report, err := a.Overlay(modsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", modsPath, err)
+		return exitFailure
+	}
+
+	for _, match := range report.Matched {
+		fmt.Printf("Replaced entry %d (%s) with %s\n", match.Index, match.Name, match.Path)
+	}
+
+	for _, path := range report.Unmatched {
+		fmt.Fprintf(os.Stderr, "Unmatched %s\n", path)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := a.PackWithOptions(
+		ctx,
+		*output,
+		afs.PackOptions{Workers: *jobs},
+		func(total, current uint32, name string) {},
+		func(total, current uint32, name string) {},
+	); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *output, err)
+		return exitFailure
+	}
+
+	fmt.Printf("%s: %d replaced, %d unmatched\n", *output, len(report.Matched), len(report.Unmatched))
+
+	return exitOk
+}
+
+func sameFile(a string, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(aInfo, bInfo)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/anasrar/afs/internal/packer"
+)
+
+func pack(args []string) int {
+	flags := flag.NewFlagSet("pack", flag.ContinueOnError)
+	jobs := flags.Int("jobs", 1, "Number of entries to pack in parallel")
+	output := ""
+	flags.StringVar(&output, "o", "", "Path of the packed AFS (default OUTPUT.AFS next to METADATA.json)")
+	flags.StringVar(&output, "output", "", "Same as -o")
+	verbose := flags.Bool("v", false, "Print every packed entry")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs pack [flags] <path to METADATA.json>\n\nWrites OUTPUT.AFS next to METADATA.json, or to -o.\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	metadataPath := positional[0]
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	count := 0
+	if err := packer.Pack(
+		ctx,
+		metadataPath,
+		packer.PackOptions{
+			Jobs:   *jobs,
+			Output: output,
+		},
+		func(total, current uint32, name string) {},
+		func(total, current uint32, name string) {
+			count += 1
+			if *verbose {
+				fmt.Printf("%d/%d %s\n", current, total, name)
+			}
+		},
+		func(message string) {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+		},
+	); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", metadataPath, err)
+		return exitFailure
+	}
+
+	fmt.Printf("%s: packed %d entries\n", metadataPath, count)
+
+	return exitOk
+}
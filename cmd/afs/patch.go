@@ -0,0 +1,174 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+func patch(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "create":
+			return patchCreate(args[1:])
+		case "apply":
+			return patchApply(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Usage: afs patch create [flags] <original AFS> <modded AFS> <output patch>\n       afs patch apply <original AFS> <patch> <output AFS>\n")
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help" || args[0] == "help") {
+		return exitOk
+	}
+	return exitUsage
+}
+
+func patchCreate(args []string) int {
+	flags := flag.NewFlagSet("patch create", flag.ContinueOnError)
+	format := flags.String("format", "afs", "Patch format, \"afs\" stores changed entries only, \"bps\" is a standard BPS patch of the whole file")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs patch create [flags] <original AFS> <modded AFS> <output patch>\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 3 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	var patchFormat afs.PatchFormat
+	switch *format {
+	case "afs":
+		patchFormat = afs.PatchFormatAfs
+	case "bps":
+		patchFormat = afs.PatchFormatBps
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown patch format %q, use afs or bps\n", *format)
+		return exitUsage
+	}
+
+	archives := []*afs.Afs{}
+	for _, afsPath := range positional[:2] {
+		a, err := afs.OpenFile(afsPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+			return exitFailure
+		}
+		defer a.Close()
+
+		archives = append(archives, a)
+	}
+
+	outputPath := positional[2]
+
+	if sameFile(positional[0], outputPath) || sameFile(positional[1], outputPath) {
+		fmt.Fprintf(os.Stderr, "Output %s would overwrite an input\n", outputPath)
+		return exitUsage
+	}
+
+	output, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	defer output.Close()
+
+	if err := afs.CreatePatch(output, archives[0], archives[1], patchFormat); err != nil {
+		output.Close()
+		os.Remove(outputPath)
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	if err := output.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	return exitOk
+}
+
+func patchApply(args []string) int {
+	flags := flag.NewFlagSet("patch apply", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs patch apply <original AFS> <patch> <output AFS>\n")
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 3 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	basePath := positional[0]
+	outputPath := positional[2]
+
+	if sameFile(basePath, outputPath) || sameFile(positional[1], outputPath) {
+		fmt.Fprintf(os.Stderr, "Output %s would overwrite an input\n", outputPath)
+		return exitUsage
+	}
+
+	base, err := os.Open(basePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	defer base.Close()
+
+	info, err := base.Stat()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	patchFile, err := os.Open(positional[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	defer patchFile.Close()
+
+	// The patched archive is written next to the output and renamed once the
+	// patch applied, so a failed patch never leaves a truncated file behind.
+	output, err := os.CreateTemp(filepath.Dir(outputPath), ".afs-patch-*")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	defer os.Remove(output.Name())
+	defer output.Close()
+
+	if err := afs.ApplyPatch(output, base, info.Size(), patchFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", basePath, err)
+		return exitFailure
+	}
+
+	if err := output.Chmod(0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	if err := output.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	if err := os.Rename(output.Name(), outputPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	return exitOk
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+func replace(args []string) int {
+	flags := flag.NewFlagSet("replace", flag.ContinueOnError)
+	name := flags.String("name", "", "New entry name (default keeps the current name)")
+	lastWriteTime := flags.String("time", "", "Last write time as \"2006-01-02 15:04:05\" (default now)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs replace [flags] <path to AFS> <entry index or name> <path to file>\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 3 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	afsPath := positional[0]
+
+	a, err := afs.OpenFileForUpdate(afsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+	defer a.Close()
+
+	index, err := findEntry(a, positional[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	source, err := afs.NewFileSource(positional[2])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	entry := a.Entries[index]
+	offset := entry.Offset
+
+	if *name == "" {
+		*name = entry.Name
+	}
+
+	timestamp := afs.NewTimestamp(time.Now())
+	if *lastWriteTime != "" {
+		if timestamp, err = afs.ParseTimestamp(*lastWriteTime); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	}
+
+	if err := a.ReplaceEntryWithNameLastWriteTime(index, source, *name, timestamp); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	if err := a.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	if entry.Offset == offset {
+		fmt.Printf("Replaced entry %d (%s) in place at 0x%X, %d bytes\n", index, entry.Name, entry.Offset, entry.Size)
+	} else {
+		fmt.Printf("Replaced entry %d (%s), relocated to 0x%X, %d bytes\n", index, entry.Name, entry.Offset, entry.Size)
+	}
+
+	return exitOk
+}
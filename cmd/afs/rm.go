@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+func rm(args []string) int {
+	flags := flag.NewFlagSet("rm", flag.ContinueOnError)
+	null := flags.Bool("null", false, "Turn the entry into a null entry instead of removing it, keeping later indices")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs rm [flags] <path to AFS> <entry index or name>\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 2 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	afsPath := positional[0]
+
+	a, err := afs.OpenFileForUpdate(afsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+	defer a.Close()
+
+	index, err := findEntry(a, positional[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	name := a.Entries[index].Name
+
+	if *null {
+		err = a.NullifyEntry(index)
+	} else {
+		err = a.RemoveEntry(index)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	if err := a.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+
+	if *null {
+		fmt.Printf("Nulled entry %d (%s)\n", index, name)
+	} else {
+		fmt.Printf("Removed entry %d (%s)\n", index, name)
+	}
+
+	return exitOk
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/anasrar/afs/internal/mods"
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+func stack(args []string) int {
+	flags := flag.NewFlagSet("stack", flag.ContinueOnError)
+	output := flags.String("o", "", "Path to the output AFS, overrides the manifest output")
+	jobs := flags.Int("jobs", 1, "Number of entries to pack in parallel")
+	asJson := flags.Bool("json", false, "Print the stack report as JSON")
+	dryRun := flags.Bool("dry-run", false, "Resolve the stack without writing the output")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs stack [flags] <path to mods.json>\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	manifest, err := mods.Load(positional[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	if *output != "" {
+		manifest.Output = *output
+	}
+
+	if !*dryRun {
+		if manifest.Output == "" {
+			fmt.Fprintf(os.Stderr, "%s: No output, set \"output\" or pass -o\n", positional[0])
+			return exitUsage
+		}
+
+		if sameFile(manifest.Base, manifest.Output) {
+			fmt.Fprintf(os.Stderr, "Output %s would overwrite the base archive\n", manifest.Output)
+			return exitUsage
+		}
+	}
+
+	a, err := afs.OpenFile(manifest.Base)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", manifest.Base, err)
+		return exitFailure
+	}
+	defer a.Close()
+
+	layers, closeLayers, err := manifest.OpenLayers(a)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	defer closeLayers()
+
+	report, err := a.Stack(layers)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	conflicts := report.Conflicts()
+
+	if *asJson {
+		buf, err := json.MarshalIndent(report, "", "\t")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		fmt.Println(string(buf))
+	} else {
+		for _, entry := range report.Entries {
+			fmt.Printf("Entry %d (%s) from %s\n", entry.Index, entry.Name, entry.Winner)
+		}
+
+		for _, entry := range conflicts {
+			fmt.Fprintf(os.Stderr, "Conflict: entry %d (%s) is changed by %s, %s wins\n", entry.Index, entry.Name, strings.Join(entry.Layers, ", "), entry.Winner)
+		}
+
+		for _, unmatched := range report.Unmatched {
+			fmt.Fprintf(os.Stderr, "Unmatched %s in %s\n", unmatched.Path, unmatched.Layer)
+		}
+	}
+
+	if *dryRun {
+		return exitOk
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := a.PackWithOptions(
+		ctx,
+		manifest.Output,
+		afs.PackOptions{Workers: *jobs},
+		func(total, current uint32, name string) {},
+		func(total, current uint32, name string) {},
+	); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", manifest.Output, err)
+		return exitFailure
+	}
+
+	if !*asJson {
+		fmt.Printf("%s: %d replaced, %d conflicts, %d unmatched\n", manifest.Output, len(report.Entries), len(conflicts), len(report.Unmatched))
+	}
+
+	return exitOk
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+func verify(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	asJson := flags.Bool("json", false, "Print the report as JSON")
+	strict := flags.Bool("strict", false, "Exit with failure on warnings too")
+	quiet := flags.Bool("quiet", false, "Do not print info level issues")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: afs verify [flags] <path to AFS>\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitCodeForParse(err)
+	}
+
+	if len(positional) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	afsPath := positional[0]
+
+	a, err := afs.OpenFileLenient(afsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", afsPath, err)
+		return exitFailure
+	}
+	defer a.Close()
+
+	report := afs.Verify(a)
+
+	if *asJson {
+		buf, err := json.MarshalIndent(report, "", "\t")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		fmt.Println(string(buf))
+	} else {
+		for _, issue := range report.Issues {
+			if *quiet && issue.Severity == afs.SeverityInfo {
+				continue
+			}
+
+			location := "archive"
+			if issue.Entry >= 0 {
+				location = fmt.Sprintf("entry %d (%s)", issue.Entry, a.Entries[issue.Entry].Name)
+			}
+			fmt.Printf("%-7s %s: %s\n", issue.Severity, location, issue.Message)
+		}
+
+		fmt.Printf(
+			"%s: %d error(s), %d warning(s), %d info\n",
+			afsPath,
+			report.Count(afs.SeverityError),
+			report.Count(afs.SeverityWarning),
+			report.Count(afs.SeverityInfo),
+		)
+	}
+
+	if report.HasErrors() || (*strict && report.Count(afs.SeverityWarning) > 0) {
+		return exitFailure
+	}
+
+	return exitOk
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/anasrar/afs/internal/metadata"
+	"github.com/anasrar/afs/internal/packer"
 	rayguistyle "github.com/anasrar/afs/internal/raygui_style"
 	"github.com/gen2brain/raylib-go/raygui"
 	rl "github.com/gen2brain/raylib-go/raylib"
@@ -63,6 +64,9 @@ func drop(filePath string) error {
 	writeLog(fmt.Sprintf("AFS Version: %X", m.Version))
 	writeLog(fmt.Sprintf("AFS Attributes Info: %d", m.AttributesInfo))
 	writeLog(fmt.Sprintf("AFS Entry Block Alignment: %d", m.EntryBlockAlignment))
+	writeLog(fmt.Sprintf("AFS Data Alignment: 0x%X", m.DataAlignment))
+	writeLog(fmt.Sprintf("AFS End Alignment: 0x%X", m.EndAlignment))
+	writeLog(fmt.Sprintf("AFS Padding Fill: 0x%02X", m.PaddingFill))
 	writeLog(fmt.Sprintf("AFS Entry Total: %d", m.EntryTotal))
 	writeLog("Ready")
 
@@ -81,7 +85,7 @@ func gui() {
 			width = float32(rl.GetScreenWidth())
 			height = float32(rl.GetScreenHeight())
 
-			logRectangle = rl.NewRectangle(0, 0, width, height-48)
+			logRectangle = rl.NewRectangle(0, 0, width, height-88)
 			logContentRectangle.Width = width - 20
 		}
 
@@ -93,6 +97,7 @@ func gui() {
 				metadataPath = ""
 			} else {
 				metadataPath = filePath
+				outputPath = packer.DefaultPackOutput(filePath)
 			}
 
 			rl.UnloadDroppedFiles()
@@ -147,6 +152,19 @@ func gui() {
 
 			logAutoScroll = raygui.CheckBox(rl.NewRectangle(98, height-30, 12, 12), "Auto Scroll", logAutoScroll)
 
+			if packing {
+				raygui.Disable()
+			}
+
+			raygui.Label(rl.NewRectangle(8, height-80, 52, 32), "Output")
+			if raygui.TextBox(rl.NewRectangle(60, height-80, width-68, 32), &outputPath, 256, outputEdit) {
+				outputEdit = !outputEdit
+			}
+
+			if packing {
+				raygui.Enable()
+			}
+
 			if packing || metadataPath == "" {
 				raygui.Disable()
 			}
@@ -155,9 +173,13 @@ func gui() {
 				ctx, cancel = context.WithCancel(context.Background())
 
 				go func() {
-					if err := pack(
+					if err := packer.Pack(
 						ctx,
 						metadataPath,
+						packer.PackOptions{
+							Jobs:   jobs,
+							Output: outputPath,
+						},
 						func(total, current uint32, name string) {
 							writeLog(fmt.Sprintf("%d/%d(%s): start", current, total, name))
 						},
@@ -171,6 +193,9 @@ func gui() {
 								progress = 0
 							}
 						},
+						func(message string) {
+							writeLog(fmt.Sprintf("Warning: %s", message))
+						},
 					); err != nil {
 						writeLog(err.Error())
 
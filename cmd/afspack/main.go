@@ -7,10 +7,15 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+
+	"github.com/anasrar/afs/internal/packer"
 )
 
 func init() {
 	flag.StringVar(&metadataPath, "metadatapath", "", "Path to METADATA.json file")
+	flag.IntVar(&jobs, "jobs", 1, "Number of entries to pack in parallel")
+	flag.StringVar(&outputPath, "o", "", "Path of the packed AFS (default OUTPUT.AFS next to METADATA.json)")
+	flag.StringVar(&outputPath, "output", "", "Same as -o")
 }
 
 func main() {
@@ -20,15 +25,22 @@ func main() {
 		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		defer cancel()
 
-		if err := pack(
+		if err := packer.Pack(
 			ctx,
 			metadataPath,
+			packer.PackOptions{
+				Jobs:   jobs,
+				Output: outputPath,
+			},
 			func(total, current uint32, name string) {
 				log.Printf("% 8d/%d(%s): start\n", current, total, name)
 			},
 			func(total, current uint32, name string) {
 				log.Printf("% 8d/%d(%s): done\n", current, total, name)
 			},
+			func(message string) {
+				log.Printf("Warning: %s\n", message)
+			},
 		); err != nil {
 			log.Fatalln(err)
 		}
@@ -10,6 +10,8 @@ import (
 var GitCommitHash = "Dev Mode"
 
 var metadataPath string
+var jobs = 1
+var outputPath string
 
 var (
 	width  float32 = 600
@@ -21,8 +23,10 @@ var ctx, cancel = context.WithCancel(context.Background())
 var packing = false
 var progress = float32(0)
 
+var outputEdit = false
+
 var logAutoScroll = true
-var logRectangle = rl.NewRectangle(0, 0, width, height-48)
+var logRectangle = rl.NewRectangle(0, 0, width, height-88)
 var logContentRectangle = rl.NewRectangle(0, 0, width-20, 48)
 var logScroll = rl.NewVector2(0, 0)
 var logView = rl.NewRectangle(0, 0, 0, 0)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/anasrar/afs/internal/packer"
 	rayguistyle "github.com/anasrar/afs/internal/raygui_style"
 	"github.com/anasrar/afs/pkg/afs"
 	"github.com/gen2brain/raylib-go/raygui"
@@ -60,7 +61,7 @@ func gui() {
 			width = float32(rl.GetScreenWidth())
 			height = float32(rl.GetScreenHeight())
 
-			logRectangle = rl.NewRectangle(0, 0, width, height-48)
+			logRectangle = rl.NewRectangle(0, 0, width, height-88)
 			logContentRectangle.Width = width - 20
 		}
 
@@ -76,10 +77,14 @@ func gui() {
 				writeLog(fmt.Sprintf("AFS Version: %X", a.Version))
 				writeLog(fmt.Sprintf("AFS Attributes Info: %d", a.AttributesInfo))
 				writeLog(fmt.Sprintf("AFS Entry Block Alignment: %d", a.EntryBlockAlignment))
+				writeLog(fmt.Sprintf("AFS Data Alignment: 0x%X", a.DataAlignment))
+				writeLog(fmt.Sprintf("AFS End Alignment: 0x%X", a.EndAlignment))
+				writeLog(fmt.Sprintf("AFS Padding Fill: 0x%02X", a.PaddingFill))
 				writeLog(fmt.Sprintf("AFS Entry Total: %d", a.EntryTotal))
 				writeLog("Ready")
 
 				afsPath = filePath
+				outputPath = packer.DefaultUnpackOutput(filePath)
 			}
 
 			rl.UnloadDroppedFiles()
@@ -134,6 +139,24 @@ func gui() {
 
 			logAutoScroll = raygui.CheckBox(rl.NewRectangle(98, height-30, 12, 12), "Auto Scroll", logAutoScroll)
 
+			if unpacking {
+				raygui.Disable()
+			}
+
+			raygui.Label(rl.NewRectangle(8, height-80, 52, 32), "Output")
+			if raygui.TextBox(rl.NewRectangle(60, height-80, width-248, 32), &outputPath, 256, outputEdit) {
+				outputEdit = !outputEdit
+			}
+
+			raygui.Label(rl.NewRectangle(width-180, height-80, 40, 32), "Files")
+			if raygui.TextBox(rl.NewRectangle(width-136, height-80, 128, 32), &filesDir, 64, filesDirEdit) {
+				filesDirEdit = !filesDirEdit
+			}
+
+			if unpacking {
+				raygui.Enable()
+			}
+
 			if unpacking || afsPath == "" {
 				raygui.Disable()
 			}
@@ -142,9 +165,16 @@ func gui() {
 				ctx, cancel = context.WithCancel(context.Background())
 
 				go func() {
-					if err := unpack(
+					if err := packer.Unpack(
 						ctx,
 						afsPath,
+						packer.UnpackOptions{
+							Jobs:           jobs,
+							PreserveLayout: preserveLayout,
+							NameEncoding:   nameEncoding,
+							Output:         outputPath,
+							FilesDir:       filesDir,
+						},
 						func(total, current uint32, name string) {
 							writeLog(fmt.Sprintf("%d/%d(%s): start", current, total, name))
 						},
@@ -158,6 +188,9 @@ func gui() {
 								progress = 0
 							}
 						},
+						func(message string) {
+							writeLog(fmt.Sprintf("Warning: %s", message))
+						},
 					); err != nil {
 						writeLog(err.Error())
 
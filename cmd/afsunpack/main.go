@@ -7,10 +7,18 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+
+	"github.com/anasrar/afs/internal/packer"
 )
 
 func init() {
 	flag.StringVar(&afsPath, "afspath", "", "Path to AFS file")
+	flag.IntVar(&jobs, "jobs", 1, "Number of entries to extract in parallel")
+	flag.BoolVar(&preserveLayout, "preserve-layout", false, "Record the original layout so afspack can reproduce the archive byte for byte")
+	flag.StringVar(&nameEncoding, "name-encoding", "", "Decode entry names as ascii, shift-jis or utf-8 (default detected)")
+	flag.StringVar(&outputPath, "o", "", "Directory for METADATA.json and the files (default UNPACK_<name> next to the AFS)")
+	flag.StringVar(&outputPath, "output", "", "Same as -o")
+	flag.StringVar(&filesDir, "files-dir", packer.DefaultFilesDir, "Directory for the files inside the output directory")
 }
 
 func main() {
@@ -20,15 +28,25 @@ func main() {
 		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		defer cancel()
 
-		if err := unpack(
+		if err := packer.Unpack(
 			ctx,
 			afsPath,
+			packer.UnpackOptions{
+				Jobs:           jobs,
+				PreserveLayout: preserveLayout,
+				NameEncoding:   nameEncoding,
+				Output:         outputPath,
+				FilesDir:       filesDir,
+			},
 			func(total, current uint32, name string) {
 				log.Printf("% 8d/%d(%s): start\n", current, total, name)
 			},
 			func(total, current uint32, name string) {
 				log.Printf("% 8d/%d(%s): done\n", current, total, name)
 			},
+			func(message string) {
+				log.Printf("Warning: %s\n", message)
+			},
 		); err != nil {
 			log.Fatalln(err)
 		}
@@ -4,12 +4,18 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/anasrar/afs/internal/packer"
 	rl "github.com/gen2brain/raylib-go/raylib"
 )
 
 var GitCommitHash = "Dev Mode"
 
 var afsPath string
+var jobs = 1
+var preserveLayout = false
+var nameEncoding = ""
+var outputPath string
+var filesDir = packer.DefaultFilesDir
 
 var (
 	width  float32 = 600
@@ -21,8 +27,11 @@ var ctx, cancel = context.WithCancel(context.Background())
 var unpacking = false
 var progress = float32(0)
 
+var outputEdit = false
+var filesDirEdit = false
+
 var logAutoScroll = true
-var logRectangle = rl.NewRectangle(0, 0, width, height-48)
+var logRectangle = rl.NewRectangle(0, 0, width, height-88)
 var logContentRectangle = rl.NewRectangle(0, 0, width-20, 48)
 var logScroll = rl.NewVector2(0, 0)
 var logView = rl.NewRectangle(0, 0, 0, 0)
@@ -2,7 +2,10 @@ module github.com/anasrar/afs
 
 go 1.23.1
 
-require github.com/gen2brain/raylib-go/raygui v0.0.0-20241016155242-81eae2921ada
+require (
+	github.com/gen2brain/raylib-go/raygui v0.0.0-20241016155242-81eae2921ada
+	golang.org/x/text v0.28.0
+)
 
 require (
 	github.com/ebitengine/purego v0.5.0 // indirect
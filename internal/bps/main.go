@@ -0,0 +1,363 @@
+package bps
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	actionSourceRead byte = iota
+	actionTargetRead
+	actionSourceCopy
+	actionTargetCopy
+)
+
+var Magic = []byte("BPS1")
+
+var (
+	ErrInvalidPatch   = errors.New("Invalid BPS patch")
+	ErrSourceMismatch = errors.New("Source does not match the BPS patch")
+	ErrTargetMismatch = errors.New("Target does not match the BPS patch")
+)
+
+type ReaderWriterAt interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+type Encoder struct {
+	writer               io.Writer
+	crc                  hash.Hash32
+	outputOffset         uint64
+	sourceRelativeOffset uint64
+	targetRelativeOffset uint64
+	err                  error
+}
+
+func NewEncoder(w io.Writer, sourceSize uint64, targetSize uint64) *Encoder {
+	crc := crc32.NewIEEE()
+	encoder := &Encoder{
+		writer: io.MultiWriter(w, crc),
+		crc:    crc,
+	}
+
+	encoder.write(Magic)
+	encoder.number(sourceSize)
+	encoder.number(targetSize)
+	encoder.number(0)
+
+	return encoder
+}
+
+func (self *Encoder) write(b []byte) {
+	if self.err != nil {
+		return
+	}
+	_, self.err = self.writer.Write(b)
+}
+
+func (self *Encoder) number(value uint64) {
+	buf := []byte{}
+	for {
+		x := byte(value & 0x7F)
+		value >>= 7
+		if value == 0 {
+			buf = append(buf, 0x80|x)
+			break
+		}
+		buf = append(buf, x)
+		value -= 1
+	}
+	self.write(buf)
+}
+
+func (self *Encoder) action(action byte, length uint64) {
+	self.number(((length - 1) << 2) | uint64(action))
+}
+
+func (self *Encoder) relative(offset uint64, base *uint64) {
+	if offset >= *base {
+		self.number((offset - *base) << 1)
+	} else {
+		self.number(((*base - offset) << 1) | 1)
+	}
+}
+
+// Copy copies length bytes from offset in the source to the current output
+// position, using the shorter SourceRead form when the offsets line up.
+func (self *Encoder) Copy(offset uint64, length uint64) error {
+	if length == 0 {
+		return self.err
+	}
+
+	if offset == self.outputOffset {
+		self.action(actionSourceRead, length)
+	} else {
+		self.action(actionSourceCopy, length)
+		self.relative(offset, &self.sourceRelativeOffset)
+		self.sourceRelativeOffset = offset + length
+	}
+
+	self.outputOffset += length
+
+	return self.err
+}
+
+func (self *Encoder) Literal(data []byte) error {
+	if len(data) == 0 {
+		return self.err
+	}
+
+	self.action(actionTargetRead, uint64(len(data)))
+	self.write(data)
+	self.outputOffset += uint64(len(data))
+
+	return self.err
+}
+
+func (self *Encoder) Fill(value byte, length uint64) error {
+	if length == 0 {
+		return self.err
+	}
+
+	if err := self.Literal([]byte{value}); err != nil || length == 1 {
+		return err
+	}
+
+	self.action(actionTargetCopy, length-1)
+	self.relative(self.outputOffset-1, &self.targetRelativeOffset)
+	self.targetRelativeOffset = self.outputOffset - 1 + length - 1
+	self.outputOffset += length - 1
+
+	return self.err
+}
+
+func (self *Encoder) Close(sourceCrc uint32, targetCrc uint32) error {
+	footer := make([]byte, 8)
+	binary.LittleEndian.PutUint32(footer, sourceCrc)
+	binary.LittleEndian.PutUint32(footer[4:], targetCrc)
+	self.write(footer)
+
+	if self.err != nil {
+		return self.err
+	}
+
+	patchCrc := make([]byte, 4)
+	binary.LittleEndian.PutUint32(patchCrc, self.crc.Sum32())
+	self.write(patchCrc)
+
+	return self.err
+}
+
+type decoder struct {
+	patch  []byte
+	offset int
+}
+
+func (self *decoder) number() (uint64, error) {
+	data := uint64(0)
+	shift := uint64(1)
+
+	for {
+		if self.offset >= len(self.patch) || shift > 1<<63 {
+			return 0, ErrInvalidPatch
+		}
+
+		x := self.patch[self.offset]
+		self.offset += 1
+
+		data += uint64(x&0x7F) * shift
+		if x&0x80 != 0 {
+			return data, nil
+		}
+
+		shift <<= 7
+		data += shift
+	}
+}
+
+func (self *decoder) relative(base *uint64) (uint64, error) {
+	value, err := self.number()
+	if err != nil {
+		return 0, err
+	}
+
+	if value&1 != 0 {
+		if value>>1 > *base {
+			return 0, ErrInvalidPatch
+		}
+		return *base - (value >> 1), nil
+	}
+
+	return *base + (value >> 1), nil
+}
+
+// Apply checks the source size and checksum, then writes the target to
+// output and verifies its checksum.
+func Apply(output ReaderWriterAt, source io.ReaderAt, sourceSize int64, patch []byte) error {
+	if len(patch) < len(Magic)+12 || !bytes.Equal(patch[:len(Magic)], Magic) {
+		return ErrInvalidPatch
+	}
+
+	footer := patch[len(patch)-12:]
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != binary.LittleEndian.Uint32(footer[8:]) {
+		return fmt.Errorf("%w, patch checksum mismatch", ErrInvalidPatch)
+	}
+
+	d := &decoder{
+		patch:  patch[:len(patch)-12],
+		offset: len(Magic),
+	}
+
+	expectedSourceSize, err := d.number()
+	if err != nil {
+		return err
+	}
+
+	targetSize, err := d.number()
+	if err != nil {
+		return err
+	}
+
+	metadataSize, err := d.number()
+	if err != nil {
+		return err
+	}
+
+	if metadataSize > uint64(len(d.patch)-d.offset) {
+		return ErrInvalidPatch
+	}
+	d.offset += int(metadataSize)
+
+	if uint64(sourceSize) != expectedSourceSize {
+		return fmt.Errorf("%w, expected %d bytes, got %d", ErrSourceMismatch, expectedSourceSize, sourceSize)
+	}
+
+	sourceCrc := crc32.NewIEEE()
+	if _, err := io.Copy(sourceCrc, io.NewSectionReader(source, 0, sourceSize)); err != nil {
+		return err
+	}
+
+	if sourceCrc.Sum32() != binary.LittleEndian.Uint32(footer) {
+		return fmt.Errorf("%w, checksum mismatch", ErrSourceMismatch)
+	}
+
+	outputOffset := uint64(0)
+	sourceRelativeOffset := uint64(0)
+	targetRelativeOffset := uint64(0)
+	buf := make([]byte, 0x10000)
+
+	copyRange := func(reader io.ReaderAt, offset uint64, length uint64) error {
+		if distance := outputOffset - offset; reader == output && distance < length && distance <= uint64(len(buf)) {
+			pattern := buf[:distance]
+			if _, err := output.ReadAt(pattern, int64(offset)); err != nil {
+				return err
+			}
+
+			repeated := bytes.Repeat(pattern, len(buf)/int(distance))
+			for length > 0 {
+				size := min(length, uint64(len(repeated)))
+				if _, err := output.WriteAt(repeated[:size], int64(outputOffset)); err != nil {
+					return err
+				}
+
+				outputOffset += size
+				length -= size
+			}
+
+			return nil
+		}
+
+		for length > 0 {
+			size := min(length, uint64(len(buf)))
+			if reader == output && offset+size > outputOffset {
+				size = min(size, outputOffset-offset)
+			}
+
+			if _, err := reader.ReadAt(buf[:size], int64(offset)); err != nil {
+				return err
+			}
+
+			if _, err := output.WriteAt(buf[:size], int64(outputOffset)); err != nil {
+				return err
+			}
+
+			offset += size
+			outputOffset += size
+			length -= size
+		}
+
+		return nil
+	}
+
+	for d.offset < len(d.patch) {
+		data, err := d.number()
+		if err != nil {
+			return err
+		}
+
+		action := byte(data & 3)
+		length := (data >> 2) + 1
+
+		if outputOffset+length > targetSize {
+			return fmt.Errorf("%w, output exceeds target size", ErrInvalidPatch)
+		}
+
+		switch action {
+		case actionSourceRead:
+			if outputOffset+length > uint64(sourceSize) {
+				return ErrInvalidPatch
+			}
+			err = copyRange(source, outputOffset, length)
+		case actionTargetRead:
+			if length > uint64(len(d.patch)-d.offset) {
+				return ErrInvalidPatch
+			}
+			_, err = output.WriteAt(d.patch[d.offset:d.offset+int(length)], int64(outputOffset))
+			d.offset += int(length)
+			outputOffset += length
+		case actionSourceCopy:
+			if sourceRelativeOffset, err = d.relative(&sourceRelativeOffset); err != nil {
+				return err
+			}
+			if sourceRelativeOffset+length > uint64(sourceSize) {
+				return ErrInvalidPatch
+			}
+			err = copyRange(source, sourceRelativeOffset, length)
+			sourceRelativeOffset += length
+		case actionTargetCopy:
+			if targetRelativeOffset, err = d.relative(&targetRelativeOffset); err != nil {
+				return err
+			}
+			if targetRelativeOffset >= outputOffset {
+				return ErrInvalidPatch
+			}
+			err = copyRange(output, targetRelativeOffset, length)
+			targetRelativeOffset += length
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if outputOffset != targetSize {
+		return fmt.Errorf("%w, expected %d bytes, got %d", ErrTargetMismatch, targetSize, outputOffset)
+	}
+
+	targetCrc := crc32.NewIEEE()
+	if _, err := io.Copy(targetCrc, io.NewSectionReader(output, 0, int64(targetSize))); err != nil {
+		return err
+	}
+
+	if targetCrc.Sum32() != binary.LittleEndian.Uint32(footer[4:]) {
+		return fmt.Errorf("%w, checksum mismatch", ErrTargetMismatch)
+	}
+
+	return nil
+}
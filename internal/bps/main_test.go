@@ -0,0 +1,67 @@
+package bps
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func _Apply(t *testing.T, source []byte, patch []byte) ([]byte, error) {
+	t.Helper()
+
+	output, err := os.Create(filepath.Join(t.TempDir(), "target"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+
+	if err := Apply(output, bytes.NewReader(source), int64(len(source)), patch); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(output.Name())
+}
+
+func TestEncodeApply(t *testing.T) {
+	source := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+	target := []byte{}
+	target = append(target, source[:10]...)
+	target = append(target, source[20:30]...)
+	target = append(target, "literal"...)
+	target = append(target, bytes.Repeat([]byte{0xEE}, 0x20000)...)
+	target = append(target, source[5:15]...)
+
+	patch := bytes.Buffer{}
+	encoder := NewEncoder(&patch, uint64(len(source)), uint64(len(target)))
+	encoder.Copy(0, 10)
+	encoder.Copy(20, 10)
+	encoder.Literal([]byte("literal"))
+	encoder.Fill(0xEE, 0x20000)
+	encoder.Copy(5, 10)
+	if err := encoder.Close(crc32.ChecksumIEEE(source), crc32.ChecksumIEEE(target)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := _Apply(t, source, patch.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, target) {
+		t.Errorf("patched output differs from the target")
+	}
+
+	if _, err := _Apply(t, []byte("another source of the same length!!!"), patch.Bytes()); !errors.Is(err, ErrSourceMismatch) {
+		t.Errorf("got %v, want %v", err, ErrSourceMismatch)
+	}
+
+	corrupt := bytes.Clone(patch.Bytes())
+	corrupt[len(Magic)+4] ^= 0xFF
+	if _, err := _Apply(t, source, corrupt); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("got %v, want %v", err, ErrInvalidPatch)
+	}
+}
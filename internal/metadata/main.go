@@ -1,18 +1,88 @@
 package metadata
 
-import "github.com/anasrar/afs/pkg/afs"
+import (
+	"fmt"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+type CustomDataPolicy string
+
+const (
+	// CustomDataKeep writes the recorded custom_data of the entry.
+	CustomDataKeep CustomDataPolicy = "keep"
+	// CustomDataSize writes the size of the packed file.
+	CustomDataSize CustomDataPolicy = "size"
+	// CustomDataFixed writes the custom_data of the metadata, the same value
+	// for every entry.
+	CustomDataFixed CustomDataPolicy = "fixed"
+)
 
 type MetadataEntry struct {
-	IsNull        bool   `json:"is_null"`
-	Source        string `json:"source"`
-	Name          string `json:"name"`
-	LastWriteTime string `json:"last_write_time"`
+	IsNull           bool             `json:"is_null"`
+	IsEmpty          bool             `json:"is_empty,omitempty"`
+	Source           string           `json:"source"`
+	Name             string           `json:"name"`
+	RawName          []byte           `json:"raw_name,omitempty"`
+	LastWriteTime    afs.Timestamp    `json:"last_write_time"`
+	Offset           uint32           `json:"offset,omitempty"`
+	CustomData       *uint32          `json:"custom_data,omitempty"`
+	CustomDataPolicy CustomDataPolicy `json:"custom_data_policy,omitempty"`
 }
 
 type Metadata struct {
 	Version             afs.Version        `json:"version"`
 	AttributesInfo      afs.AttributesInfo `json:"attributes_info"`
 	EntryBlockAlignment uint32             `json:"entry_block_alignment"`
+	DataAlignment       uint32             `json:"data_alignment,omitempty"`
+	EndAlignment        uint32             `json:"end_alignment,omitempty"`
+	PaddingFill         byte               `json:"padding_fill"`
+	NameEncoding        *afs.NameEncoding  `json:"name_encoding,omitempty"`
+	CustomDataPolicy    CustomDataPolicy   `json:"custom_data_policy,omitempty"`
+	CustomData          *uint32            `json:"custom_data,omitempty"`
 	EntryTotal          uint32             `json:"entry_total"`
 	Entries             []*MetadataEntry   `json:"entries"`
+	Layout              *afs.Layout        `json:"layout,omitempty"`
+	// Archive is the AFS, relative to the metadata, that non-null entries
+	// without a source are packed from.
+	Archive string `json:"archive,omitempty"`
+}
+
+// Policy returns the custom data policy of an entry. Without a policy on the
+// entry or the metadata, a recorded custom_data is kept, otherwise the size
+// is used, which matches metadata written before policies existed.
+func (self *Metadata) Policy(entry *MetadataEntry) CustomDataPolicy {
+	if entry.CustomDataPolicy != "" {
+		return entry.CustomDataPolicy
+	}
+
+	if self.CustomDataPolicy != "" {
+		return self.CustomDataPolicy
+	}
+
+	if entry.CustomData != nil {
+		return CustomDataKeep
+	}
+
+	return CustomDataSize
+}
+
+// ResolveCustomData returns the custom data of an entry whose file has size bytes.
+func (self *Metadata) ResolveCustomData(entry *MetadataEntry, size uint32) (uint32, error) {
+	switch policy := self.Policy(entry); policy {
+	case CustomDataKeep:
+		if entry.CustomData == nil {
+			return 0, fmt.Errorf("Entry %s has no custom_data to keep", entry.Name)
+		}
+		return *entry.CustomData, nil
+	case CustomDataSize:
+		return size, nil
+	case CustomDataFixed:
+		if self.CustomData == nil {
+			return 0, fmt.Errorf("Entry %s uses the fixed policy but the metadata has no custom_data", entry.Name)
+		}
+		return *self.CustomData, nil
+	default:
+		return 0, fmt.Errorf("Entry %s has unknown custom_data_policy %q, use keep, size or fixed", entry.Name, policy)
+	}
 }
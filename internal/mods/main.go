@@ -0,0 +1,119 @@
+package mods
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+type Mod struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// Manifest describes a mod stack. Mods are applied in order, so a later mod
+// wins over an earlier one. Relative paths are resolved from the manifest.
+type Manifest struct {
+	Base   string `json:"base"`
+	Output string `json:"output,omitempty"`
+	Mods   []*Mod `json:"mods"`
+}
+
+func Load(path string) (*Manifest, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(buf, manifest); err != nil {
+		return nil, fmt.Errorf("Parsing %s: %w", path, err)
+	}
+
+	if manifest.Base == "" {
+		return nil, fmt.Errorf("%s: Missing base", path)
+	}
+
+	dir := filepath.Dir(path)
+	manifest.Base = resolve(dir, manifest.Base)
+	if manifest.Output != "" {
+		manifest.Output = resolve(dir, manifest.Output)
+	}
+
+	names := map[string]bool{}
+	for i, mod := range manifest.Mods {
+		if mod.Path == "" {
+			return nil, fmt.Errorf("%s: Mod %d has no path", path, i)
+		}
+
+		mod.Path = resolve(dir, mod.Path)
+		if mod.Name == "" {
+			mod.Name = filepath.Base(mod.Path)
+		}
+
+		if names[mod.Name] {
+			return nil, fmt.Errorf("%s: Duplicate mod name %s", path, mod.Name)
+		}
+		names[mod.Name] = true
+	}
+
+	return manifest, nil
+}
+
+// OpenLayers opens every mod as a layer, a directory as loose files and
+// anything else as an AFS archive compared against base. Call close after
+// packing.
+func (self *Manifest) OpenLayers(base *afs.Afs) ([]*afs.Layer, func(), error) {
+	archives := []*afs.Afs{}
+	close := func() {
+		for _, archive := range archives {
+			archive.Close()
+		}
+	}
+
+	layers := []*afs.Layer{}
+	for _, mod := range self.Mods {
+		info, err := os.Stat(mod.Path)
+		if err != nil {
+			close()
+			return nil, nil, err
+		}
+
+		if info.IsDir() {
+			layer, err := afs.NewDirLayer(mod.Name, mod.Path)
+			if err != nil {
+				close()
+				return nil, nil, err
+			}
+			layers = append(layers, layer)
+			continue
+		}
+
+		archive, err := afs.OpenFile(mod.Path)
+		if err != nil {
+			close()
+			return nil, nil, fmt.Errorf("%s: %w", mod.Path, err)
+		}
+		archives = append(archives, archive)
+
+		layer, err := afs.NewArchiveLayer(mod.Name, archive, base)
+		if err != nil {
+			close()
+			return nil, nil, fmt.Errorf("%s: %w", mod.Path, err)
+		}
+		layers = append(layers, layer)
+	}
+
+	return layers, close, nil
+}
+
+func resolve(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,149 @@
+package packer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anasrar/afs/internal/metadata"
+	"github.com/anasrar/afs/internal/utils"
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+type PackOptions struct {
+	// Jobs is the number of entries packed in parallel.
+	Jobs int
+	// Output is the path of the packed AFS, empty means OUTPUT.AFS next to
+	// the metadata.
+	Output string
+}
+
+func DefaultPackOutput(metadataPath string) string {
+	return fmt.Sprintf("%s/OUTPUT.AFS", utils.ParentDirectory(metadataPath))
+}
+
+// Pack builds an AFS from a METADATA.json written by Unpack, see PackOptions.
+func Pack(
+	ctx context.Context,
+	metadataPath string,
+	options PackOptions,
+	onStart,
+	onDone func(total uint32, current uint32, name string),
+	onWarning func(message string),
+) error {
+	metadataBuf, err := os.ReadFile(metadataPath)
+	if err != nil {
+		return err
+	}
+
+	var m metadata.Metadata
+	if err := json.Unmarshal(metadataBuf, &m); err != nil {
+		return err
+	}
+
+	a := afs.New()
+	a.Version = m.Version
+	a.AttributesInfo = m.AttributesInfo
+	a.EntryBlockAlignment = m.EntryBlockAlignment
+	a.PaddingFill = m.PaddingFill
+
+	// Metadata written before name encodings stored names as UTF-8.
+	a.NameEncoding = afs.NameEncodingUTF8
+	if m.NameEncoding != nil {
+		a.NameEncoding = *m.NameEncoding
+	}
+
+	if m.DataAlignment != 0 {
+		a.DataAlignment = m.DataAlignment
+	}
+
+	if m.EndAlignment != 0 {
+		a.EndAlignment = m.EndAlignment
+	}
+
+	parentDir := utils.ParentDirectory(metadataPath)
+
+	var archive *afs.Afs
+	if m.Archive != "" {
+		archive, err = afs.OpenFile(fmt.Sprintf("%s/%s", parentDir, m.Archive))
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+	}
+
+	for i, entry := range m.Entries {
+		if entry.IsNull {
+			a.AddNullEntry(entry.Name)
+		} else if entry.Source == "" {
+			if archive == nil || i >= len(archive.Entries) || archive.Entries[i].IsNull {
+				return fmt.Errorf("Entry %d (%s) has no source and no archive entry to pack from", i, entry.Name)
+			}
+
+			if err := a.AddEntryFromSource(
+				afs.NewEntrySource(archive.Entries[i]),
+				entry.Name,
+				entry.LastWriteTime,
+			); err != nil {
+				return err
+			}
+		} else {
+			if err := a.AddEntryFromPathWithNameLastWriteTime(
+				fmt.Sprintf("%s/%s", parentDir, entry.Source),
+				entry.Name,
+				entry.LastWriteTime,
+			); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, entry := range m.Entries {
+		if entry.IsNull {
+			continue
+		}
+
+		a.Entries[i].RawName = entry.RawName
+
+		customData, err := m.ResolveCustomData(entry, a.Entries[i].Size)
+		if err != nil {
+			return err
+		}
+		a.Entries[i].CustomData = customData
+
+		if customData != a.Entries[i].Size {
+			onWarning(fmt.Sprintf("Entry %d (%s) custom data 0x%X is not its size %d, written as %s", i, entry.Name, customData, a.Entries[i].Size, m.Policy(entry)))
+		}
+	}
+
+	if m.Layout != nil {
+		a.Layout = m.Layout
+		a.Layout.Offsets = make([]uint32, len(m.Entries))
+		for i, entry := range m.Entries {
+			a.Layout.Offsets[i] = entry.Offset
+		}
+	}
+
+	outputPath := options.Output
+	if outputPath == "" {
+		outputPath = DefaultPackOutput(metadataPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
+		return err
+	}
+
+	if err := a.PackWithOptions(
+		ctx,
+		outputPath,
+		afs.PackOptions{Workers: options.Jobs},
+		onStart,
+		onDone,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
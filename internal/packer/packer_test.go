@@ -0,0 +1,162 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+func _TestProgress(total uint32, current uint32, name string) {}
+
+func _TestWarning(message string) {}
+
+func _TestData(seed int, size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(seed*31 + i*7 + i/251)
+	}
+	return data
+}
+
+// _BuildTestArchive packs a small archive with duplicate, unsafe, empty and
+// null entries, one with custom data that is not its size, and returns its
+// path.
+func _BuildTestArchive(t *testing.T) string {
+	t.Helper()
+
+	timestamp := afs.Timestamp{Year: 2024, Month: 5, Day: 6, Hour: 7, Minute: 8, Second: 9}
+
+	a := afs.New()
+	for i, name := range []string{"a.bin", "a.bin", "../up.bin", "", "b.bin"} {
+		if err := a.AddEntryFromSource(afs.NewBytesSource(_TestData(i, 1000*i+1)), name, timestamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.AddNullEntry("")
+	if err := a.AddEntryFromSource(afs.NewBytesSource(nil), "empty.bin", timestamp); err != nil {
+		t.Fatal(err)
+	}
+	a.Entries[4].CustomData = 0xDEADBEEF
+
+	path := filepath.Join(t.TempDir(), "test.afs")
+	if err := a.Pack(context.Background(), path, _TestProgress, _TestProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func _ReadTestFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// _UnpackPack unpacks the archive at path with options, packs it back and
+// returns the packed bytes.
+func _UnpackPack(t *testing.T, path string, options UnpackOptions) []byte {
+	t.Helper()
+
+	options.Output = filepath.Join(t.TempDir(), "unpacked")
+	if err := Unpack(context.Background(), path, options, _TestProgress, _TestProgress, _TestWarning); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(t.TempDir(), "packed.afs")
+	packOptions := PackOptions{Jobs: options.Jobs, Output: output}
+	if err := Pack(context.Background(), filepath.Join(options.Output, "METADATA.json"), packOptions, _TestProgress, _TestProgress, _TestWarning); err != nil {
+		t.Fatal(err)
+	}
+
+	return _ReadTestFile(t, output)
+}
+
+func TestUnpackPackRoundTrip(t *testing.T) {
+	path := _BuildTestArchive(t)
+	want := _ReadTestFile(t, path)
+
+	for _, jobs := range []int{1, 4} {
+		if got := _UnpackPack(t, path, UnpackOptions{Jobs: jobs}); !bytes.Equal(got, want) {
+			t.Errorf("jobs %d: packed archive differs from the original", jobs)
+		}
+	}
+}
+
+func TestUnpackFilesStayInFilesDir(t *testing.T) {
+	path := _BuildTestArchive(t)
+	output := filepath.Join(t.TempDir(), "unpacked")
+
+	if err := Unpack(context.Background(), path, UnpackOptions{Jobs: 1, Output: output}, _TestProgress, _TestProgress, _TestWarning); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(output, "up.bin")); err == nil {
+		t.Errorf("entry named ../up.bin was written outside the files directory")
+	}
+
+	files, err := os.ReadDir(filepath.Join(output, DefaultFilesDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 6 {
+		t.Errorf("got %d files, want one for each of the 6 entries that are not null", len(files))
+	}
+}
+
+func TestUnpackSelectFullMetadata(t *testing.T) {
+	path := _BuildTestArchive(t)
+	want := _ReadTestFile(t, path)
+
+	options := UnpackOptions{
+		Jobs:         1,
+		FullMetadata: true,
+		Select: func(index int, name string) bool {
+			return index == 1
+		},
+	}
+
+	if got := _UnpackPack(t, path, options); !bytes.Equal(got, want) {
+		t.Errorf("packed archive differs from the original")
+	}
+}
+
+func TestUnpackPackPreserveLayout(t *testing.T) {
+	path := _BuildTestArchive(t)
+
+	a, err := afs.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := a.Entries[4]
+	stray := int64(last.Offset + last.Size)
+	a.Close()
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteAt([]byte("stray bytes"), stray); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	want := _ReadTestFile(t, path)
+
+	if got := _UnpackPack(t, path, UnpackOptions{Jobs: 2, PreserveLayout: true}); !bytes.Equal(got, want) {
+		t.Errorf("packed archive is not byte identical")
+	}
+
+	if got := _UnpackPack(t, path, UnpackOptions{Jobs: 2}); bytes.Equal(got, want) {
+		t.Errorf("stray bytes were kept without preserving the layout")
+	}
+}
@@ -0,0 +1,201 @@
+package packer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anasrar/afs/internal/metadata"
+	"github.com/anasrar/afs/internal/utils"
+	"github.com/anasrar/afs/pkg/afs"
+)
+
+const DefaultFilesDir = "FILES"
+
+var (
+	ErrSubsetLayout = errors.New("Preserving the layout needs every entry, use full metadata")
+	ErrFilesDir     = errors.New("Files directory must be a relative path inside the output directory")
+)
+
+type UnpackOptions struct {
+	// Jobs is the number of entries extracted in parallel.
+	Jobs int
+	// PreserveLayout records the layout so Pack reproduces the archive byte
+	// for byte.
+	PreserveLayout bool
+	// NameEncoding overrides the detected name encoding when not empty.
+	NameEncoding string
+	// Select, when set, extracts only the entries it returns true for. The
+	// name is the decoded entry name before duplicates are renamed.
+	Select func(index int, name string) bool
+	// FullMetadata describes every entry in METADATA.json when Select is set,
+	// entries that are not extracted are packed from the original archive.
+	FullMetadata bool
+	// Output is the directory for METADATA.json and the files, empty means
+	// UNPACK_<name> next to the AFS.
+	Output string
+	// FilesDir is the directory for the files inside Output, empty means FILES.
+	FilesDir string
+}
+
+func DefaultUnpackOutput(afsPath string) string {
+	return fmt.Sprintf("%s/UNPACK_%s", utils.ParentDirectory(afsPath), utils.Basename(afsPath))
+}
+
+// Unpack extracts an AFS into <output>/FILES and writes
+// <output>/METADATA.json, see UnpackOptions.
+func Unpack(
+	ctx context.Context,
+	afsPath string,
+	options UnpackOptions,
+	onStart,
+	onDone func(total uint32, current uint32, name string),
+	onWarning func(message string),
+) error {
+	a, err := afs.OpenFile(afsPath)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	if options.NameEncoding != "" {
+		encoding, err := afs.ParseNameEncoding(options.NameEncoding)
+		if err != nil {
+			return err
+		}
+		a.SetNameEncoding(encoding)
+	}
+
+	selected := make([]bool, len(a.Entries))
+	selectedCount := 0
+	for i, entry := range a.Entries {
+		selected[i] = options.Select == nil || options.Select(i, entry.Name)
+		if selected[i] {
+			selectedCount += 1
+		}
+	}
+
+	subset := selectedCount < len(a.Entries) && !options.FullMetadata
+	if subset && options.PreserveLayout {
+		return ErrSubsetLayout
+	}
+
+	if options.PreserveLayout {
+		if err := a.CaptureLayout(); err != nil {
+			return err
+		}
+	}
+
+	outputDirPath := options.Output
+	if outputDirPath == "" {
+		outputDirPath = DefaultUnpackOutput(afsPath)
+	}
+	outputMetadataPath := fmt.Sprintf("%s/METADATA.json", outputDirPath)
+
+	filesDir := DefaultFilesDir
+	if options.FilesDir != "" {
+		filesDir = filepath.ToSlash(filepath.Clean(options.FilesDir))
+		if !filepath.IsLocal(filesDir) {
+			return fmt.Errorf("%w, got %s", ErrFilesDir, options.FilesDir)
+		}
+	}
+
+	md := metadata.Metadata{
+		Version:             a.Version,
+		AttributesInfo:      a.AttributesInfo,
+		EntryBlockAlignment: a.EntryBlockAlignment,
+		DataAlignment:       a.DataAlignment,
+		EndAlignment:        a.EndAlignment,
+		PaddingFill:         a.PaddingFill,
+		CustomDataPolicy:    metadata.CustomDataSize,
+		NameEncoding:        &a.NameEncoding,
+		EntryTotal:          a.EntryTotal,
+		Entries:             []*metadata.MetadataEntry{},
+		Layout:              a.Layout,
+	}
+
+	if selectedCount < len(a.Entries) && options.FullMetadata {
+		archivePath, err := filepath.Rel(outputDirPath, afsPath)
+		if err != nil {
+			return err
+		}
+		md.Archive = filepath.ToSlash(archivePath)
+	}
+
+	names := afs.UniqueNames(a.Entries)
+
+	for i, entry := range a.Entries {
+		name := entry.Name
+		customData := entry.CustomData
+
+		if subset && !selected[i] {
+			continue
+		}
+
+		metadataEntry := &metadata.MetadataEntry{
+			IsNull:        entry.IsNull,
+			IsEmpty:       entry.IsEmpty(),
+			Source:        fmt.Sprintf("%s/%s", filesDir, names[i]),
+			Name:          name,
+			RawName:       entry.RawName,
+			LastWriteTime: entry.LastWriteTime,
+		}
+
+		if !entry.IsNull {
+			metadataEntry.CustomData = &customData
+
+			if customData != entry.Size {
+				metadataEntry.CustomDataPolicy = metadata.CustomDataKeep
+				onWarning(fmt.Sprintf("Entry %d (%s) custom data 0x%X is not its size %d, kept as is", i, name, customData, entry.Size))
+			}
+		}
+
+		if options.PreserveLayout && !entry.IsNull {
+			metadataEntry.Offset = entry.Offset
+		}
+
+		if !selected[i] {
+			metadataEntry.Source = ""
+		}
+
+		md.Entries = append(md.Entries, metadataEntry)
+	}
+
+	md.EntryTotal = uint32(len(md.Entries))
+
+	outputFilesDirPath := fmt.Sprintf("%s/%s", outputDirPath, filesDir)
+	if err := os.MkdirAll(outputFilesDirPath, os.ModePerm); err != nil {
+		return err
+	}
+
+	unpackOptions := afs.UnpackOptions{
+		Workers: options.Jobs,
+		Filter: func(index int, entry *afs.Entry) bool {
+			return selected[index]
+		},
+	}
+
+	if err := a.UnpackWithOptions(ctx, outputFilesDirPath, unpackOptions, onStart, onDone); err != nil {
+		return err
+	}
+
+	buf, err := json.MarshalIndent(md, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	metadataFile, err := os.OpenFile(outputMetadataPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer metadataFile.Close()
+
+	if _, err := metadataFile.Write(buf); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package selector
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const RegexPrefix = "re:"
+
+var _RangesPattern = regexp.MustCompile(`^[\d\s,-]+$`)
+
+type _Range struct {
+	start int
+	// end is inclusive, -1 means up to the last entry.
+	end int
+}
+
+// Selector matches entries by index range ("10-20,35" or "100-"), name glob
+// ("*.adx") or name regex ("re:^bgm_\d+"). An entry is selected when it
+// matches any include, or every entry when there is none, and no exclude.
+type Selector struct {
+	include []func(index int, name string) bool
+	exclude []func(index int, name string) bool
+}
+
+func New(include []string, exclude []string) (*Selector, error) {
+	selector := &Selector{}
+
+	for _, pattern := range include {
+		match, err := parse(pattern)
+		if err != nil {
+			return nil, err
+		}
+		selector.include = append(selector.include, match)
+	}
+
+	for _, pattern := range exclude {
+		match, err := parse(pattern)
+		if err != nil {
+			return nil, err
+		}
+		selector.exclude = append(selector.exclude, match)
+	}
+
+	return selector, nil
+}
+
+func (self *Selector) IsAll() bool {
+	return len(self.include) == 0 && len(self.exclude) == 0
+}
+
+func (self *Selector) Match(index int, name string) bool {
+	for _, match := range self.exclude {
+		if match(index, name) {
+			return false
+		}
+	}
+
+	if len(self.include) == 0 {
+		return true
+	}
+
+	for _, match := range self.include {
+		if match(index, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parse(pattern string) (func(index int, name string) bool, error) {
+	if expression, found := strings.CutPrefix(pattern, RegexPrefix); found {
+		re, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regex %q: %w", expression, err)
+		}
+		return func(index int, name string) bool {
+			return re.MatchString(name)
+		}, nil
+	}
+
+	if _RangesPattern.MatchString(pattern) {
+		ranges, err := parseRanges(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return func(index int, name string) bool {
+			for _, r := range ranges {
+				if index >= r.start && (r.end < 0 || index <= r.end) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("Invalid glob %q: %w", pattern, err)
+	}
+	return func(index int, name string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}, nil
+}
+
+func parseRanges(pattern string) ([]*_Range, error) {
+	ranges := []*_Range{}
+
+	for _, part := range strings.Split(pattern, ",") {
+		startText, endText, isRange := strings.Cut(strings.TrimSpace(part), "-")
+
+		start, err := strconv.Atoi(startText)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid index range %q", part)
+		}
+
+		r := &_Range{start: start, end: start}
+		if isRange {
+			r.end = -1
+			if endText != "" {
+				end, err := strconv.Atoi(endText)
+				if err != nil || end < start {
+					return nil, fmt.Errorf("Invalid index range %q", part)
+				}
+				r.end = end
+			}
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
@@ -1,5 +1,7 @@
 package afs
 
+import "fmt"
+
 type AttributesInfo int
 
 const (
@@ -7,3 +9,16 @@ const (
 	AttributesInfoInfoAtStart
 	AttributesInfoInfoAtEnd
 )
+
+func (self AttributesInfo) String() string {
+	switch self {
+	case AttributesInfoNoAttribute:
+		return "none"
+	case AttributesInfoInfoAtStart:
+		return "start"
+	case AttributesInfoInfoAtEnd:
+		return "end"
+	default:
+		return fmt.Sprintf("attributes_info(%d)", int(self))
+	}
+}
@@ -0,0 +1,277 @@
+package afs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+type DiffMatch int
+
+const (
+	DiffMatchName DiffMatch = iota
+	DiffMatchIndex
+)
+
+type ChangeKind int
+
+const (
+	ChangeAdded ChangeKind = iota
+	ChangeRemoved
+	ChangeNull
+	ChangeMoved
+	ChangeRenamed
+	ChangeResized
+	ChangeContent
+	ChangeLastWriteTime
+	ChangeCustomData
+)
+
+func (self ChangeKind) String() string {
+	switch self {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeNull:
+		return "null"
+	case ChangeMoved:
+		return "moved"
+	case ChangeRenamed:
+		return "renamed"
+	case ChangeResized:
+		return "resized"
+	case ChangeContent:
+		return "content"
+	case ChangeLastWriteTime:
+		return "last_write_time"
+	case ChangeCustomData:
+		return "custom_data"
+	default:
+		return fmt.Sprintf("change(%d)", int(self))
+	}
+}
+
+func (self ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(self.String()), nil
+}
+
+type HeaderChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type Change struct {
+	Kind     ChangeKind `json:"kind"`
+	OldIndex int        `json:"old_index"`
+	NewIndex int        `json:"new_index"`
+	Name     string     `json:"name"`
+	Old      string     `json:"old,omitempty"`
+	New      string     `json:"new,omitempty"`
+}
+
+type DiffReport struct {
+	Header  []*HeaderChange `json:"header"`
+	Changes []*Change       `json:"changes"`
+}
+
+func (self *DiffReport) Equal() bool {
+	return len(self.Header) == 0 && len(self.Changes) == 0
+}
+
+func (self *DiffReport) header(field string, old, new any) {
+	oldValue := fmt.Sprint(old)
+	newValue := fmt.Sprint(new)
+	if oldValue == newValue {
+		return
+	}
+
+	self.Header = append(
+		self.Header,
+		&HeaderChange{
+			Field: field,
+			Old:   oldValue,
+			New:   newValue,
+		},
+	)
+}
+
+func (self *DiffReport) add(kind ChangeKind, oldIndex int, newIndex int, name string, old string, new string) {
+	self.Changes = append(
+		self.Changes,
+		&Change{
+			Kind:     kind,
+			OldIndex: oldIndex,
+			NewIndex: newIndex,
+			Name:     name,
+			Old:      old,
+			New:      new,
+		},
+	)
+}
+
+type DiffOptions struct {
+	// Match pairs entries by name, reporting moves between indices, or by
+	// index, reporting renames. Null entries only take part in index matching.
+	Match DiffMatch
+	// SkipContent compares sizes only and does not read entry data.
+	SkipContent bool
+}
+
+func Diff(a *Afs, b *Afs, options DiffOptions) (*DiffReport, error) {
+	report := &DiffReport{
+		Header:  []*HeaderChange{},
+		Changes: []*Change{},
+	}
+
+	report.header("version", a.Version, b.Version)
+	report.header("attributes_info", a.AttributesInfo, b.AttributesInfo)
+	report.header("entry_block_alignment", fmt.Sprintf("0x%X", a.EntryBlockAlignment), fmt.Sprintf("0x%X", b.EntryBlockAlignment))
+	report.header("data_alignment", fmt.Sprintf("0x%X", a.DataAlignment), fmt.Sprintf("0x%X", b.DataAlignment))
+	report.header("end_alignment", fmt.Sprintf("0x%X", a.EndAlignment), fmt.Sprintf("0x%X", b.EndAlignment))
+	report.header("name_encoding", a.NameEncoding, b.NameEncoding)
+	report.header("padding_fill", fmt.Sprintf("0x%02X", a.PaddingFill), fmt.Sprintf("0x%02X", b.PaddingFill))
+	report.header("entry_total", a.EntryTotal, b.EntryTotal)
+
+	if options.Match == DiffMatchIndex {
+		for i := 0; i < max(len(a.Entries), len(b.Entries)); i++ {
+			switch {
+			case i >= len(b.Entries):
+				report.add(ChangeRemoved, i, -1, a.Entries[i].Name, _DescribeEntry(a.Entries[i]), "")
+			case i >= len(a.Entries):
+				report.add(ChangeAdded, -1, i, b.Entries[i].Name, "", _DescribeEntry(b.Entries[i]))
+			case a.Entries[i].IsNull != b.Entries[i].IsNull:
+				name := a.Entries[i].Name
+				if a.Entries[i].IsNull {
+					name = b.Entries[i].Name
+				}
+				report.add(ChangeNull, i, i, name, _DescribeEntry(a.Entries[i]), _DescribeEntry(b.Entries[i]))
+			case a.Entries[i].IsNull:
+			default:
+				if err := _DiffEntries(report, a, i, b, i, options); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return report, nil
+	}
+
+	aNames := UniqueNames(a.Entries)
+	bNames := UniqueNames(b.Entries)
+
+	bIndices := map[string]int{}
+	for j, entry := range b.Entries {
+		if !entry.IsNull {
+			bIndices[bNames[j]] = j
+		}
+	}
+
+	matched := map[int]bool{}
+	for i, entry := range a.Entries {
+		if entry.IsNull {
+			continue
+		}
+
+		j, found := bIndices[aNames[i]]
+		if !found {
+			report.add(ChangeRemoved, i, -1, aNames[i], _DescribeEntry(entry), "")
+			continue
+		}
+		matched[j] = true
+
+		if i != j {
+			report.add(ChangeMoved, i, j, aNames[i], fmt.Sprint(i), fmt.Sprint(j))
+		}
+
+		if err := _DiffEntries(report, a, i, b, j, options); err != nil {
+			return nil, err
+		}
+	}
+
+	for j, entry := range b.Entries {
+		if !entry.IsNull && !matched[j] {
+			report.add(ChangeAdded, -1, j, bNames[j], "", _DescribeEntry(entry))
+		}
+	}
+
+	return report, nil
+}
+
+func _DiffEntries(report *DiffReport, a *Afs, i int, b *Afs, j int, options DiffOptions) error {
+	aEntry := a.Entries[i]
+	bEntry := b.Entries[j]
+
+	if aEntry.Name != bEntry.Name {
+		report.add(ChangeRenamed, i, j, bEntry.Name, aEntry.Name, bEntry.Name)
+	}
+
+	if aEntry.Size != bEntry.Size {
+		report.add(ChangeResized, i, j, bEntry.Name, fmt.Sprint(aEntry.Size), fmt.Sprint(bEntry.Size))
+	} else if !options.SkipContent {
+		equal, err := _EqualContent(aEntry, bEntry)
+		if err != nil {
+			return _EntryError(j, bEntry, err)
+		}
+
+		if !equal {
+			report.add(ChangeContent, i, j, bEntry.Name, "", "")
+		}
+	}
+
+	if aEntry.LastWriteTime != bEntry.LastWriteTime {
+		report.add(ChangeLastWriteTime, i, j, bEntry.Name, aEntry.LastWriteTime.String(), bEntry.LastWriteTime.String())
+	}
+
+	if aEntry.CustomData != bEntry.CustomData {
+		report.add(ChangeCustomData, i, j, bEntry.Name, fmt.Sprintf("0x%08X", aEntry.CustomData), fmt.Sprintf("0x%08X", bEntry.CustomData))
+	}
+
+	return nil
+}
+
+func _EqualContent(a *Entry, b *Entry) (bool, error) {
+	aReader, err := a.Open()
+	if err != nil {
+		return false, err
+	}
+	defer aReader.Close()
+
+	bReader, err := b.Open()
+	if err != nil {
+		return false, err
+	}
+	defer bReader.Close()
+
+	aBuf := make([]byte, 0x10000)
+	bBuf := make([]byte, 0x10000)
+
+	for remaining := a.Size; remaining > 0; {
+		size := min(remaining, uint32(len(aBuf)))
+
+		if _, err := io.ReadFull(aReader, aBuf[:size]); err != nil {
+			return false, err
+		}
+
+		if _, err := io.ReadFull(bReader, bBuf[:size]); err != nil {
+			return false, err
+		}
+
+		if !bytes.Equal(aBuf[:size], bBuf[:size]) {
+			return false, nil
+		}
+
+		remaining -= size
+	}
+
+	return true, nil
+}
+
+func _DescribeEntry(entry *Entry) string {
+	if entry.IsNull {
+		return "null"
+	}
+
+	return fmt.Sprintf("%d bytes", entry.Size)
+}
@@ -0,0 +1,547 @@
+package afs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"time"
+)
+
+type _Truncater interface {
+	Truncate(size int64) error
+}
+
+func OpenFileForUpdate(filePath string) (*Afs, error) {
+	file, err := os.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	result := New()
+	if err := result.unmarshal(filePath, file, info.Size()); err != nil {
+		file.Close()
+		return nil, err
+	}
+	result.closer = file
+	result.writer = file
+
+	return result, nil
+}
+
+func (self *Afs) ReplaceEntry(index int, source EntrySource) error {
+	if index < 0 || index >= len(self.Entries) {
+		return fmt.Errorf("%w: %d", ErrEntryOutOfRange, index)
+	}
+
+	return self.ReplaceEntryWithNameLastWriteTime(
+		index,
+		source,
+		self.Entries[index].Name,
+		NewTimestamp(time.Now()),
+	)
+}
+
+func (self *Afs) ReplaceEntryWithNameLastWriteTime(index int, source EntrySource, name string, lastWriteTime Timestamp) error {
+	if self.writer == nil {
+		return ErrNotWritable
+	}
+
+	if index < 0 || index >= len(self.Entries) {
+		return fmt.Errorf("%w: %d", ErrEntryOutOfRange, index)
+	}
+
+	entry := self.Entries[index]
+
+	replaced, err := self.newEditEntry(source, name, lastWriteTime)
+	if err != nil {
+		return _EntryError(index, entry, err)
+	}
+
+	if name == entry.Name {
+		replaced.RawName = entry.RawName
+	}
+
+	// Custom data that is not the size, such as a CRC or type ID, is kept.
+	if !entry.IsNull && entry.CustomData != entry.Size {
+		replaced.CustomData = entry.CustomData
+	}
+
+	limit := self.slotLimit(index)
+	if entry.IsNull || limit < entry.Offset || replaced.Size > limit-entry.Offset {
+		records, err := self.readAttributeRecords()
+		if err != nil {
+			return err
+		}
+
+		*entry = *replaced
+		if records[index], err = self.encodeAttribute(entry); err != nil {
+			return _EntryError(index, entry, err)
+		}
+
+		return self.commit(records, map[*Entry]EntrySource{entry: source})
+	}
+
+	replaced.Offset = entry.Offset
+
+	if err := self.writeEntryData(replaced, source); err != nil {
+		return _EntryError(index, entry, err)
+	}
+
+	if end, oldEnd := replaced.Offset+replaced.Size, entry.Offset+entry.Size; oldEnd > end {
+		if err := self.writePadding(end, oldEnd); err != nil {
+			return err
+		}
+	}
+
+	*entry = *replaced
+	entry.reader = self.reader
+
+	if err := self.writeEntryInfo(index); err != nil {
+		return err
+	}
+
+	return self.writeAttribute(index)
+}
+
+// AppendEntry writes a new entry to the end of the archive file, unlike
+// AddEntryFromSource which only adds it to the in-memory archive for Pack.
+func (self *Afs) AppendEntry(source EntrySource, name string, lastWriteTime Timestamp) error {
+	return self.InsertEntry(len(self.Entries), source, name, lastWriteTime)
+}
+
+func (self *Afs) InsertEntry(index int, source EntrySource, name string, lastWriteTime Timestamp) error {
+	if self.writer == nil {
+		return ErrNotWritable
+	}
+
+	if index < 0 || index > len(self.Entries) {
+		return fmt.Errorf("%w: %d", ErrEntryOutOfRange, index)
+	}
+
+	entry, err := self.newEditEntry(source, name, lastWriteTime)
+	if err != nil {
+		return _EntryError(index, &Entry{Name: name}, err)
+	}
+
+	record, err := self.encodeAttribute(entry)
+	if err != nil {
+		return _EntryError(index, entry, err)
+	}
+
+	records, err := self.readAttributeRecords()
+	if err != nil {
+		return err
+	}
+
+	self.Entries = slices.Insert(self.Entries, index, entry)
+
+	return self.commit(slices.Insert(records, index, record), map[*Entry]EntrySource{entry: source})
+}
+
+func (self *Afs) InsertNullEntry(index int) error {
+	if self.writer == nil {
+		return ErrNotWritable
+	}
+
+	if index < 0 || index > len(self.Entries) {
+		return fmt.Errorf("%w: %d", ErrEntryOutOfRange, index)
+	}
+
+	records, err := self.readAttributeRecords()
+	if err != nil {
+		return err
+	}
+
+	self.Entries = slices.Insert(self.Entries, index, &Entry{IsNull: true})
+
+	return self.commit(slices.Insert(records, index, make([]byte, AttributeElementSize)), nil)
+}
+
+func (self *Afs) RemoveEntry(index int) error {
+	if self.writer == nil {
+		return ErrNotWritable
+	}
+
+	if index < 0 || index >= len(self.Entries) {
+		return fmt.Errorf("%w: %d", ErrEntryOutOfRange, index)
+	}
+
+	records, err := self.readAttributeRecords()
+	if err != nil {
+		return err
+	}
+
+	self.Entries = slices.Delete(self.Entries, index, index+1)
+
+	return self.commit(slices.Delete(records, index, index+1), nil)
+}
+
+func (self *Afs) NullifyEntry(index int) error {
+	if self.writer == nil {
+		return ErrNotWritable
+	}
+
+	if index < 0 || index >= len(self.Entries) {
+		return fmt.Errorf("%w: %d", ErrEntryOutOfRange, index)
+	}
+
+	records, err := self.readAttributeRecords()
+	if err != nil {
+		return err
+	}
+
+	*self.Entries[index] = Entry{IsNull: true}
+	records[index] = make([]byte, AttributeElementSize)
+
+	return self.commit(records, nil)
+}
+
+func (self *Afs) MoveEntry(from int, to int) error {
+	if self.writer == nil {
+		return ErrNotWritable
+	}
+
+	if from < 0 || from >= len(self.Entries) {
+		return fmt.Errorf("%w: %d", ErrEntryOutOfRange, from)
+	}
+
+	if to < 0 || to >= len(self.Entries) {
+		return fmt.Errorf("%w: %d", ErrEntryOutOfRange, to)
+	}
+
+	records, err := self.readAttributeRecords()
+	if err != nil {
+		return err
+	}
+
+	entry := self.Entries[from]
+	record := records[from]
+
+	self.Entries = slices.Insert(slices.Delete(self.Entries, from, from+1), to, entry)
+
+	return self.commit(slices.Insert(slices.Delete(records, from, from+1), to, record), nil)
+}
+
+func (self *Afs) newEditEntry(source EntrySource, name string, lastWriteTime Timestamp) (*Entry, error) {
+	size := source.Size()
+	if size < 0 || size > math.MaxUint32 {
+		return nil, fmt.Errorf("%w, got %d bytes", ErrEntrySizeOutOfRange, size)
+	}
+
+	entry := &Entry{
+		Name:          name,
+		Size:          uint32(size),
+		LastWriteTime: lastWriteTime,
+		CustomData:    uint32(size),
+	}
+
+	if err := _ValidateEntry(entry, self.AttributesInfo, self.NameEncoding); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// commit writes pending entry data after all other data, moving any entry the
+// entry table would now overlap, then rewrites the entry table, attribute
+// table and attribute info from the in-memory entries and records.
+func (self *Afs) commit(records [][]byte, pending map[*Entry]EntrySource) error {
+	if pending == nil {
+		pending = map[*Entry]EntrySource{}
+	}
+
+	if err := _ValidateAlignment(self.DataAlignment, self.EndAlignment); err != nil {
+		return err
+	}
+
+	entryTotal := uint32(len(self.Entries))
+	previousTableEnd := HeaderSize + (self.EntryTotal * EntryInfoElementSize)
+	tableEnd := HeaderSize + (entryTotal * EntryInfoElementSize)
+	if self.AttributesInfo == AttributesInfoInfoAtStart {
+		previousTableEnd += AttributeInfoSize
+		tableEnd += AttributeInfoSize
+	}
+
+	first := -1
+	for i, entry := range self.Entries {
+		if entry.IsNull {
+			continue
+		}
+
+		if first < 0 {
+			first = i
+		}
+
+		if _, found := pending[entry]; !found && entry.Offset < tableEnd {
+			pending[entry] = NewSectionSource(self.reader, int64(entry.Offset), int64(entry.Size))
+		}
+	}
+
+	if first >= 0 && self.AttributesInfo == AttributesInfoInfoAtEnd {
+		entry := self.Entries[first]
+		if _, found := pending[entry]; !found && !self.isFree(entry.Offset-AttributeInfoSize, AttributeInfoSize, tableEnd, pending) {
+			pending[entry] = NewSectionSource(self.reader, int64(entry.Offset), int64(entry.Size))
+		}
+	}
+
+	// Pending entries still read from the archive count too, so no entry is
+	// written over data that is yet to be copied.
+	end := uint64(_FirstEntryOffset(entryTotal, AlignmentSize))
+	for _, entry := range self.Entries {
+		if _, found := pending[entry]; (!found || entry.reader != nil) && !entry.IsNull {
+			end = max(end, uint64(entry.Offset)+uint64(entry.Size))
+		}
+	}
+
+	for i, entry := range self.Entries {
+		source, found := pending[entry]
+		if !found {
+			continue
+		}
+
+		if i == first && self.AttributesInfo == AttributesInfoInfoAtEnd {
+			end += uint64(AttributeInfoSize)
+		}
+
+		if end > math.MaxUint32 || uint64(_Pad(uint32(end), self.DataAlignment))+uint64(entry.Size) > math.MaxUint32 {
+			return _EntryError(i, entry, ErrArchiveTooLarge)
+		}
+
+		moved := *entry
+		moved.Offset = _Pad(uint32(end), self.DataAlignment)
+		if err := self.writeEntryData(&moved, source); err != nil {
+			return _EntryError(i, entry, err)
+		}
+
+		dataEnd := moved.Offset + moved.Size
+		if err := self.writePadding(dataEnd, _Pad(dataEnd, self.DataAlignment)); err != nil {
+			return err
+		}
+
+		entry.Offset = moved.Offset
+		entry.reader = self.reader
+		end = uint64(dataEnd)
+	}
+
+	fileEnd := uint32(end)
+
+	if self.AttributesInfo != AttributesInfoNoAttribute {
+		if self.attributesOffset < tableEnd || self.attributesOffset < fileEnd {
+			self.attributesOffset = _Pad(fileEnd, self.DataAlignment)
+		}
+
+		attributes := bytes.Join(records, nil)
+		if _, err := self.writer.WriteAt(attributes, int64(self.attributesOffset)); err != nil {
+			return err
+		}
+
+		self.attributesSize = uint32(len(attributes))
+		fileEnd = self.attributesOffset + self.attributesSize
+	}
+
+	header := bytes.Buffer{}
+	if err := _WriteHeader(&header, self.Version, self.Entries); err != nil {
+		return err
+	}
+
+	if _, err := self.writer.WriteAt(header.Bytes(), 0); err != nil {
+		return err
+	}
+
+	if previousTableEnd > tableEnd && self.isFree(tableEnd, previousTableEnd-tableEnd, tableEnd, nil) {
+		if err := self.writePadding(tableEnd, previousTableEnd); err != nil {
+			return err
+		}
+	}
+
+	self.EntryTotal = entryTotal
+
+	if err := self.writeAttributesInfo(); err != nil {
+		return err
+	}
+
+	if err := self.writePadding(fileEnd, _Pad(fileEnd, self.EndAlignment)); err != nil {
+		return err
+	}
+
+	return self.resize(int64(_Pad(fileEnd, self.EndAlignment)))
+}
+
+func (self *Afs) isFree(start uint32, size uint32, tableEnd uint32, pending map[*Entry]EntrySource) bool {
+	if start < tableEnd || uint64(start)+uint64(size) > math.MaxUint32 {
+		return false
+	}
+
+	for _, entry := range self.Entries {
+		if _, found := pending[entry]; found || entry.IsNull {
+			continue
+		}
+
+		if start < entry.Offset+entry.Size && entry.Offset < start+size {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (self *Afs) slotLimit(index int) uint32 {
+	entry := self.Entries[index]
+	limit := uint32(min(self.size, math.MaxUint32))
+
+	for i, other := range self.Entries {
+		if i == index || other.IsNull {
+			continue
+		}
+
+		if other.Offset >= entry.Offset && other.Offset < limit {
+			limit = other.Offset
+		}
+	}
+
+	if self.AttributesInfo != AttributesInfoNoAttribute {
+		if self.attributesOffset >= entry.Offset && self.attributesOffset < limit {
+			limit = self.attributesOffset
+		}
+
+		if position, found := self.attributesInfoPosition(); found && position >= entry.Offset && position < limit {
+			limit = position
+		}
+	}
+
+	return limit
+}
+
+func (self *Afs) attributesInfoPosition() (uint32, bool) {
+	switch self.AttributesInfo {
+	case AttributesInfoInfoAtStart:
+		return HeaderSize + (uint32(len(self.Entries)) * EntryInfoElementSize), true
+	case AttributesInfoInfoAtEnd:
+		for _, entry := range self.Entries {
+			if !entry.IsNull {
+				return entry.Offset - AttributeInfoSize, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func (self *Afs) writeEntryData(entry *Entry, source EntrySource) error {
+	reader, err := source.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return _CopyEntry(io.NewOffsetWriter(self.writer, int64(entry.Offset)), reader, entry)
+}
+
+func (self *Afs) writeEntryInfo(index int) error {
+	entry := self.Entries[index]
+
+	buf := make([]byte, EntryInfoElementSize)
+	if !entry.IsNull {
+		binary.LittleEndian.PutUint32(buf, entry.Offset)
+		binary.LittleEndian.PutUint32(buf[4:], entry.Size)
+	}
+
+	_, err := self.writer.WriteAt(buf, int64(HeaderSize+(uint32(index)*EntryInfoElementSize)))
+	return err
+}
+
+func (self *Afs) writeAttribute(index int) error {
+	if self.AttributesInfo == AttributesInfoNoAttribute {
+		return nil
+	}
+
+	record, err := self.encodeAttribute(self.Entries[index])
+	if err != nil {
+		return _EntryError(index, self.Entries[index], err)
+	}
+
+	_, err = self.writer.WriteAt(record, int64(self.attributesOffset+(uint32(index)*AttributeElementSize)))
+	return err
+}
+
+func (self *Afs) writeAttributesInfo() error {
+	position, found := self.attributesInfoPosition()
+	if !found {
+		return nil
+	}
+
+	for _, entry := range self.Entries {
+		if !entry.IsNull && position < entry.Offset+entry.Size && entry.Offset < position+AttributeInfoSize {
+			return fmt.Errorf("No room for attribute info at 0x%X", position)
+		}
+	}
+
+	buf := make([]byte, AttributeInfoSize)
+	binary.LittleEndian.PutUint32(buf, self.attributesOffset)
+	binary.LittleEndian.PutUint32(buf[4:], self.attributesSize)
+
+	_, err := self.writer.WriteAt(buf, int64(position))
+	return err
+}
+
+func (self *Afs) encodeAttribute(entry *Entry) ([]byte, error) {
+	if self.AttributesInfo == AttributesInfoNoAttribute {
+		return nil, nil
+	}
+
+	buf := bytes.Buffer{}
+	if err := _WriteAttributes(&buf, []*Entry{entry}, self.NameEncoding); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (self *Afs) readAttributeRecords() ([][]byte, error) {
+	records := make([][]byte, len(self.Entries))
+	if self.AttributesInfo == AttributesInfoNoAttribute {
+		return records, nil
+	}
+
+	buf := make([]byte, uint32(len(self.Entries))*AttributeElementSize)
+	if _, err := self.reader.ReadAt(buf, int64(self.attributesOffset)); err != nil {
+		return nil, _ReadError("attribute table", err)
+	}
+
+	for i := range records {
+		records[i] = buf[uint32(i)*AttributeElementSize : uint32(i+1)*AttributeElementSize]
+	}
+
+	return records, nil
+}
+
+func (self *Afs) writePadding(start uint32, end uint32) error {
+	if end <= start {
+		return nil
+	}
+
+	_, err := self.writer.WriteAt(bytes.Repeat([]byte{self.PaddingFill}, int(end-start)), int64(start))
+	return err
+}
+
+func (self *Afs) resize(size int64) error {
+	if truncater, ok := self.writer.(_Truncater); ok {
+		if err := truncater.Truncate(size); err != nil {
+			return err
+		}
+	}
+
+	self.size = size
+
+	return nil
+}
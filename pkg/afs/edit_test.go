@@ -0,0 +1,196 @@
+package afs
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func _OpenTestArchiveForUpdate(t *testing.T, path string) *Afs {
+	t.Helper()
+
+	a, err := OpenFileForUpdate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+
+	return a
+}
+
+func TestEditOperations(t *testing.T) {
+	replacement := _TestEntry{name: "replacement.bin", data: _TestData(10, 5000)}
+	small := _TestEntry{name: "small.bin", data: _TestData(11, 100)}
+	added := _TestEntry{name: "added.bin", data: _TestData(12, 0x1234)}
+
+	tests := []struct {
+		name string
+		edit func(a *Afs) error
+		want func(entries []_TestEntry) []_TestEntry
+	}{
+		{
+			name: "replace in place",
+			edit: func(a *Afs) error {
+				return a.ReplaceEntryWithNameLastWriteTime(0, NewBytesSource(small.data), small.name, _TestTimestamp)
+			},
+			want: func(entries []_TestEntry) []_TestEntry {
+				entries[0] = small
+				return entries
+			},
+		},
+		{
+			name: "replace with larger data",
+			edit: func(a *Afs) error {
+				return a.ReplaceEntryWithNameLastWriteTime(4, NewBytesSource(replacement.data), replacement.name, _TestTimestamp)
+			},
+			want: func(entries []_TestEntry) []_TestEntry {
+				entries[4] = replacement
+				return entries
+			},
+		},
+		{
+			name: "replace null entry",
+			edit: func(a *Afs) error {
+				return a.ReplaceEntryWithNameLastWriteTime(1, NewBytesSource(replacement.data), replacement.name, _TestTimestamp)
+			},
+			want: func(entries []_TestEntry) []_TestEntry {
+				entries[1] = replacement
+				return entries
+			},
+		},
+		{
+			name: "append",
+			edit: func(a *Afs) error {
+				return a.AppendEntry(NewBytesSource(added.data), added.name, _TestTimestamp)
+			},
+			want: func(entries []_TestEntry) []_TestEntry {
+				return append(entries, added)
+			},
+		},
+		{
+			name: "insert",
+			edit: func(a *Afs) error {
+				return a.InsertEntry(0, NewBytesSource(added.data), added.name, _TestTimestamp)
+			},
+			want: func(entries []_TestEntry) []_TestEntry {
+				return slices.Insert(entries, 0, added)
+			},
+		},
+		{
+			name: "insert null",
+			edit: func(a *Afs) error {
+				return a.InsertNullEntry(2)
+			},
+			want: func(entries []_TestEntry) []_TestEntry {
+				return slices.Insert(entries, 2, _TestEntry{null: true})
+			},
+		},
+		{
+			name: "remove",
+			edit: func(a *Afs) error {
+				return a.RemoveEntry(3)
+			},
+			want: func(entries []_TestEntry) []_TestEntry {
+				return slices.Delete(entries, 3, 4)
+			},
+		},
+		{
+			name: "nullify",
+			edit: func(a *Afs) error {
+				return a.NullifyEntry(0)
+			},
+			want: func(entries []_TestEntry) []_TestEntry {
+				entries[0] = _TestEntry{null: true}
+				return entries
+			},
+		},
+		{
+			name: "move",
+			edit: func(a *Afs) error {
+				return a.MoveEntry(0, 4)
+			},
+			want: func(entries []_TestEntry) []_TestEntry {
+				entry := entries[0]
+				return slices.Insert(slices.Delete(entries, 0, 1), 4, entry)
+			},
+		},
+	}
+
+	for _, attributesInfo := range []AttributesInfo{AttributesInfoNoAttribute, AttributesInfoInfoAtStart, AttributesInfoInfoAtEnd} {
+		for _, test := range tests {
+			t.Run(attributesInfo.String()+"/"+test.name, func(t *testing.T) {
+				path := _BuildTestArchive(t, func(a *Afs) { a.AttributesInfo = attributesInfo }, _TestEntries())
+
+				a := _OpenTestArchiveForUpdate(t, path)
+				if err := test.edit(a); err != nil {
+					t.Fatal(err)
+				}
+				if err := a.Close(); err != nil {
+					t.Fatal(err)
+				}
+
+				edited := _OpenTestArchive(t, path)
+				if edited.AttributesInfo != attributesInfo {
+					t.Fatalf("attributes info is %s, want %s", edited.AttributesInfo, attributesInfo)
+				}
+
+				_CheckTestArchive(t, edited, test.want(_TestEntries()))
+			})
+		}
+	}
+}
+
+func TestEditReplaceKeepsCustomData(t *testing.T) {
+	a := New()
+	for i, name := range []string{"crc.bin", "size.bin"} {
+		if err := a.AddEntryFromSource(NewBytesSource(_TestData(i, 300)), name, _TestTimestamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.Entries[0].CustomData = 0xDEADBEEF
+
+	path := filepath.Join(t.TempDir(), "custom.afs")
+	if err := a.Pack(context.Background(), path, _TestProgress, _TestProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	edit := _OpenTestArchiveForUpdate(t, path)
+	for i := range edit.Entries {
+		if err := edit.ReplaceEntry(i, NewBytesSource(_TestData(i+2, 200))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	edit.Close()
+
+	edited := _OpenTestArchive(t, path)
+	if custom := edited.Entries[0].CustomData; custom != 0xDEADBEEF {
+		t.Errorf("custom data is 0x%X, want 0xDEADBEEF kept", custom)
+	}
+	if custom := edited.Entries[1].CustomData; custom != 200 {
+		t.Errorf("custom data is %d, want the new size 200", custom)
+	}
+}
+
+// TestEditInsertNullEntryMovesData grows an entry table that ends exactly at
+// the first entry data, so the data has to move out of the way.
+func TestEditInsertNullEntryMovesData(t *testing.T) {
+	entries := []_TestEntry{{name: "large.bin", data: _TestData(1, 200*1024)}}
+	for len(entries) < 254 {
+		entries = append(entries, _TestEntry{null: true})
+	}
+
+	path := _BuildTestArchive(t, nil, entries)
+
+	a := _OpenTestArchiveForUpdate(t, path)
+	if a.Entries[0].Offset != HeaderSize+(254*EntryInfoElementSize)+AttributeInfoSize {
+		t.Fatalf("first entry is at 0x%X, not right after the entry table", a.Entries[0].Offset)
+	}
+
+	if err := a.InsertNullEntry(254); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+
+	_CheckTestArchive(t, _OpenTestArchive(t, path), append(entries, _TestEntry{null: true}))
+}
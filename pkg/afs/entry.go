@@ -1,11 +1,76 @@
 package afs
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
 type Entry struct {
-	Source        string `json:"source"`
-	Offset        uint32 `json:"offset"`
-	Name          string `json:"name"`
-	Size          uint32 `json:"size"`
-	LastWriteTime string `json:"last_write_time"`
-	CustomData    uint32 `json:"custom_data"`
-	IsNull        bool   `json:"is_null"`
+	Source        string    `json:"source"`
+	Offset        uint32    `json:"offset"`
+	Name          string    `json:"name"`
+	RawName       []byte    `json:"raw_name,omitempty"`
+	Size          uint32    `json:"size"`
+	LastWriteTime Timestamp `json:"last_write_time"`
+	CustomData    uint32    `json:"custom_data"`
+	IsNull        bool      `json:"is_null"`
+
+	Data EntrySource `json:"-"`
+
+	reader io.ReaderAt
+}
+
+type _EntryReader struct {
+	*io.SectionReader
+	closer io.Closer
+}
+
+func (self *_EntryReader) Close() error {
+	if self.closer == nil {
+		return nil
+	}
+	return self.closer.Close()
+}
+
+// IsEmpty reports an entry that is present in the archive, with an offset and
+// an attribute record, but has no data. A null entry has offset 0 instead.
+func (self *Entry) IsEmpty() bool {
+	return !self.IsNull && self.Size == 0
+}
+
+// Open returns a reader for the entry data. Data read from the archive or a
+// file also implements io.Seeker and io.ReaderAt, data from an EntrySource
+// does when the source returns such a reader.
+func (self *Entry) Open() (io.ReadCloser, error) {
+	if self.IsNull {
+		return &_EntryReader{
+			SectionReader: io.NewSectionReader(bytes.NewReader(nil), 0, 0),
+		}, nil
+	}
+
+	if self.Data != nil {
+		return self.Data.Open()
+	}
+
+	if self.reader != nil {
+		return &_EntryReader{
+			SectionReader: io.NewSectionReader(self.reader, int64(self.Offset), int64(self.Size)),
+		}, nil
+	}
+
+	if self.Source == "" {
+		return nil, fmt.Errorf("%w, %s", ErrEntryNoSource, self.Name)
+	}
+
+	file, err := os.Open(self.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &_EntryReader{
+		SectionReader: io.NewSectionReader(file, int64(self.Offset), int64(self.Size)),
+		closer:        file,
+	}, nil
 }
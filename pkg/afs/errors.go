@@ -0,0 +1,71 @@
+package afs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrInvalidSignature     = errors.New("Invalid signature")
+	ErrUnsupportedVersion   = errors.New("Unsupported version")
+	ErrTruncated            = errors.New("Archive is truncated")
+	ErrEntryOutOfRange      = errors.New("Entry index out of range")
+	ErrEntryTotalMismatch   = errors.New("Entry total does not match entry count")
+	ErrEntryNameTooLong     = errors.New("Entry name is too long")
+	ErrEntryNameEncoding    = errors.New("Entry name cannot be encoded")
+	ErrUnknownNameEncoding  = errors.New("Unknown name encoding")
+	ErrInvalidLastWriteTime = errors.New("Invalid last write time")
+	ErrEntryNoSource        = errors.New("Entry has no source")
+	ErrArchiveTooLarge      = errors.New("Archive exceeds 4 GiB")
+	ErrInvalidAlignment     = errors.New("Invalid alignment")
+	ErrWriterClosed         = errors.New("Writer is closed")
+	ErrEntrySizeOutOfRange  = errors.New("Entry size out of range")
+	ErrNotArchiveBacked     = errors.New("Archive is not backed by a reader")
+	ErrNotWritable          = errors.New("Archive is not open for update")
+	ErrInvalidPatch         = errors.New("Invalid patch")
+	ErrPatchBaseMismatch    = errors.New("Base archive does not match the patch")
+	ErrPatchTargetMismatch  = errors.New("Patched archive does not match the patch target")
+	ErrLayoutMismatch       = errors.New("Entries do not fit the recorded layout")
+)
+
+type EntryError struct {
+	Index  int
+	Name   string
+	Offset uint32
+	Err    error
+}
+
+func (self *EntryError) Error() string {
+	return fmt.Sprintf("Entry %d (%s) at 0x%X: %s", self.Index, self.Name, self.Offset, self.Err)
+}
+
+func (self *EntryError) Unwrap() error {
+	return self.Err
+}
+
+func _EntryError(index int, entry *Entry, err error) error {
+	var entryError *EntryError
+	if errors.As(err, &entryError) {
+		return err
+	}
+
+	return &EntryError{
+		Index:  index,
+		Name:   entry.Name,
+		Offset: entry.Offset,
+		Err:    err,
+	}
+}
+
+func _ReadError(section string, err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w while reading %s", ErrTruncated, section)
+	}
+
+	return fmt.Errorf("Reading %s: %w", section, err)
+}
+
+func _CanceledError(cause error) error {
+	return fmt.Errorf("Canceled: %w", cause)
+}
@@ -0,0 +1,124 @@
+package afs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// FileTypeHeaderSize is the number of leading bytes DetectFileType looks at.
+const FileTypeHeaderSize = 0x40
+
+type _FileSignature struct {
+	fileType string
+	offset   int
+	magic    []byte
+}
+
+var _FileSignatures = []*_FileSignature{
+	{"afs", 0, []byte("AFS\x00")},
+	{"awb", 0, []byte("AFS2")},
+	{"acb", 0, []byte("@UTF")},
+	{"cpk", 0, []byte("CPK ")},
+	{"usm", 0, []byte("CRID")},
+	{"hca", 0, []byte("HCA\x00")},
+	{"hca", 0, []byte{0xC8, 0xC3, 0xC1, 0x00}},
+	{"pvr", 0, []byte("GBIX")},
+	{"pvr", 0, []byte("PVRT")},
+	{"gvr", 0, []byte("GCIX")},
+	{"gvr", 0, []byte("GVRT")},
+	{"tm2", 0, []byte("TIM2")},
+	{"gim", 0, []byte("MIG.00.1PSP")},
+	{"dds", 0, []byte("DDS ")},
+	{"png", 0, []byte("\x89PNG\r\n\x1A\n")},
+	{"jpg", 0, []byte{0xFF, 0xD8, 0xFF}},
+	{"gif", 0, []byte("GIF8")},
+	{"bmp", 0, []byte("BM")},
+	{"ogg", 0, []byte("OggS")},
+	{"mid", 0, []byte("MThd")},
+	{"sfd", 0, []byte{0x00, 0x00, 0x01, 0xBA}},
+	{"gz", 0, []byte{0x1F, 0x8B}},
+	{"zip", 0, []byte("PK\x03\x04")},
+	{"elf", 0, []byte("\x7FELF")},
+}
+
+// FileTypeHeaderLength returns how many leading bytes DetectFileType needs
+// given the first FileTypeHeaderSize bytes. It is more for ADX and AHX, which
+// are recognized by a copyright string at an offset stored in the header.
+func FileTypeHeaderLength(header []byte) int {
+	if copyright, ok := _AdxCopyrightOffset(header); ok {
+		return max(FileTypeHeaderSize, copyright+4)
+	}
+	return FileTypeHeaderSize
+}
+
+func _AdxCopyrightOffset(header []byte) (int, bool) {
+	if len(header) < 4 || header[0] != 0x80 || header[1] != 0x00 {
+		return 0, false
+	}
+
+	copyright := int(binary.BigEndian.Uint16(header[2:]))
+	return copyright, copyright >= 2
+}
+
+// DetectFileType guesses a file type from the first FileTypeHeaderLength
+// bytes of an entry, returning a short extension such as "adx" or "png", or
+// "" when the data is not recognized.
+func DetectFileType(header []byte) string {
+	if len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) {
+		switch string(header[8:12]) {
+		case "WAVE":
+			return "wav"
+		case "AVI ":
+			return "avi"
+		default:
+			return "riff"
+		}
+	}
+
+	if copyright, ok := _AdxCopyrightOffset(header); ok {
+		if copyright+4 <= len(header) && bytes.Equal(header[copyright-2:copyright+4], []byte("(c)CRI")) {
+			if header[4] == 0x10 || header[4] == 0x11 {
+				return "ahx"
+			}
+			return "adx"
+		}
+	}
+
+	for _, signature := range _FileSignatures {
+		end := signature.offset + len(signature.magic)
+		if end <= len(header) && bytes.Equal(header[signature.offset:end], signature.magic) {
+			return signature.fileType
+		}
+	}
+
+	return ""
+}
+
+// FileType reads the start of the entry data and returns DetectFileType.
+func (self *Entry) FileType() (string, error) {
+	if self.IsNull || self.Size == 0 {
+		return "", nil
+	}
+
+	reader, err := self.Open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	header := make([]byte, min(self.Size, FileTypeHeaderSize))
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return "", err
+	}
+
+	if length := min(uint32(FileTypeHeaderLength(header)), self.Size); length > uint32(len(header)) {
+		rest := make([]byte, length-uint32(len(header)))
+		if _, err := io.ReadFull(reader, rest); err != nil {
+			return "", err
+		}
+		header = append(header, rest...)
+	}
+
+	return DetectFileType(header), nil
+}
@@ -0,0 +1,60 @@
+package afs
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func _TestAdx(copyright int, encoding byte) []byte {
+	data := make([]byte, copyright+0x100)
+	data[0] = 0x80
+	binary.BigEndian.PutUint16(data[2:], uint16(copyright))
+	data[4] = encoding
+	copy(data[copyright-2:], "(c)CRI")
+	return data
+}
+
+func TestDetectFileType(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"png", []byte("\x89PNG\r\n\x1A\n\x00\x00\x00\x0DIHDR"), "png"},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), "wav"},
+		{"adx", _TestAdx(0x20, 0x03), "adx"},
+		{"adx with a long header", _TestAdx(0x11E, 0x03), "adx"},
+		{"ahx", _TestAdx(0x20, 0x11), "ahx"},
+		{"adx without copyright", _TestAdx(0x20, 0x03)[:0x10], ""},
+		{"unknown", []byte("plain text"), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := test.data[:min(len(test.data), FileTypeHeaderSize)]
+			header = test.data[:min(len(test.data), FileTypeHeaderLength(header))]
+
+			if got := DetectFileType(header); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEntryFileType(t *testing.T) {
+	a := _OpenTestArchive(t, _BuildTestArchive(t, nil, []_TestEntry{
+		{name: "voice.bin", data: _TestAdx(0x11E, 0x03)},
+		{null: true},
+		{name: "empty.bin", data: []byte{}},
+	}))
+
+	for i, want := range []string{"adx", "", ""} {
+		got, err := a.Entries[i].FileType()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("entry %d: got %q, want %q", i, got, want)
+		}
+	}
+}
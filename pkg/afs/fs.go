@@ -0,0 +1,244 @@
+package afs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+type FS struct {
+	entries []*Entry
+	names   []string
+	index   map[string]int
+}
+
+func (self *Afs) FS(showNullEntries bool) *FS {
+	result := &FS{
+		entries: []*Entry{},
+		names:   []string{},
+		index:   map[string]int{},
+	}
+
+	for i, name := range UniqueNames(self.Entries) {
+		entry := self.Entries[i]
+		if entry.IsNull && !showNullEntries {
+			continue
+		}
+
+		result.index[name] = len(result.entries)
+		result.entries = append(result.entries, entry)
+		result.names = append(result.names, name)
+	}
+
+	return result
+}
+
+func (self *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." {
+		return &_FSDir{fs: self}, nil
+	}
+
+	i, found := self.index[name]
+	if !found {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	reader, err := self.entries[i].Open()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	file := &_FSFile{
+		ReadCloser: reader,
+		info:       self.fileInfo(i),
+	}
+
+	if seeker, ok := reader.(_SeekerAt); ok {
+		return &_FSSeekFile{_FSFile: file, seeker: seeker}, nil
+	}
+
+	return file, nil
+}
+
+func (self *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." {
+		return _FSDirInfo(), nil
+	}
+
+	i, found := self.index[name]
+	if !found {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return self.fileInfo(i), nil
+}
+
+func (self *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name != "." {
+		if _, found := self.index[name]; found {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("Not a directory")}
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return self.dirEntries(), nil
+}
+
+func (self *FS) ReadFile(name string) ([]byte, error) {
+	file, err := self.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fmt.Errorf("Is a directory")}
+	}
+
+	buf := make([]byte, info.Size())
+	if _, err := io.ReadFull(file, buf); err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	return buf, nil
+}
+
+func (self *FS) fileInfo(i int) *_FSFileInfo {
+	entry := self.entries[i]
+
+	modTime, valid := entry.LastWriteTime.Time()
+	if !valid {
+		modTime = time.Time{}
+	}
+
+	return &_FSFileInfo{
+		name:    self.names[i],
+		size:    int64(entry.Size),
+		mode:    0444,
+		modTime: modTime,
+	}
+}
+
+func (self *FS) dirEntries() []fs.DirEntry {
+	result := make([]fs.DirEntry, 0, len(self.entries))
+	for i := range self.entries {
+		result = append(result, fs.FileInfoToDirEntry(self.fileInfo(i)))
+	}
+
+	slices.SortFunc(result, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	return result
+}
+
+type _FSFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func _FSDirInfo() *_FSFileInfo {
+	return &_FSFileInfo{
+		name: ".",
+		mode: fs.ModeDir | 0555,
+	}
+}
+
+func (self *_FSFileInfo) Name() string       { return self.name }
+func (self *_FSFileInfo) Size() int64        { return self.size }
+func (self *_FSFileInfo) Mode() fs.FileMode  { return self.mode }
+func (self *_FSFileInfo) ModTime() time.Time { return self.modTime }
+func (self *_FSFileInfo) IsDir() bool        { return self.mode.IsDir() }
+func (self *_FSFileInfo) Sys() any           { return nil }
+
+type _FSFile struct {
+	io.ReadCloser
+	info *_FSFileInfo
+}
+
+func (self *_FSFile) Stat() (fs.FileInfo, error) {
+	return self.info, nil
+}
+
+type _SeekerAt interface {
+	io.Seeker
+	io.ReaderAt
+}
+
+// _FSSeekFile is a file whose entry reader can seek, so io.Seeker and
+// io.ReaderAt are only offered when they work.
+type _FSSeekFile struct {
+	*_FSFile
+	seeker _SeekerAt
+}
+
+func (self *_FSSeekFile) Seek(offset int64, whence int) (int64, error) {
+	return self.seeker.Seek(offset, whence)
+}
+
+func (self *_FSSeekFile) ReadAt(p []byte, off int64) (int, error) {
+	return self.seeker.ReadAt(p, off)
+}
+
+type _FSDir struct {
+	fs      *FS
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (self *_FSDir) Stat() (fs.FileInfo, error) {
+	return _FSDirInfo(), nil
+}
+
+func (self *_FSDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: fmt.Errorf("Is a directory")}
+}
+
+func (self *_FSDir) Close() error {
+	return nil
+}
+
+func (self *_FSDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if self.entries == nil {
+		self.entries = self.fs.dirEntries()
+	}
+
+	remaining := self.entries[self.offset:]
+	if n <= 0 {
+		self.offset = len(self.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	self.offset += n
+
+	return remaining[:n], nil
+}
@@ -0,0 +1,442 @@
+package afs
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+const _LayoutChunkSize uint32 = 0x100000
+const _LayoutGapMergeDistance uint32 = 0x10
+
+type Gap struct {
+	Offset uint32 `json:"offset"`
+	Data   []byte `json:"data"`
+}
+
+type Layout struct {
+	Offsets          []uint32 `json:"-"`
+	AttributesOffset uint32   `json:"attributes_offset"`
+	AttributesSize   uint32   `json:"attributes_size"`
+	FileSize         uint32   `json:"file_size"`
+	Gaps             []*Gap   `json:"gaps,omitempty"`
+}
+
+type _Interval struct {
+	start uint32
+	end   uint32
+}
+
+// _Structure is header, entry table or attribute bytes written from the
+// entries. Loose structures are the rows of null entries, whose original bytes
+// are not read into the entries and are kept as gaps when they differ.
+type _Structure struct {
+	offset uint32
+	data   []byte
+	loose  bool
+}
+
+func (self *Afs) CaptureLayout() error {
+	if self.reader == nil {
+		return ErrNotArchiveBacked
+	}
+
+	if self.size > int64(^uint32(0)) {
+		return ErrArchiveTooLarge
+	}
+
+	layout := &Layout{
+		Offsets:          make([]uint32, len(self.Entries)),
+		AttributesOffset: self.attributesOffset,
+		AttributesSize:   self.attributesSize,
+		FileSize:         uint32(self.size),
+		Gaps:             []*Gap{},
+	}
+
+	for i, entry := range self.Entries {
+		if !entry.IsNull {
+			layout.Offsets[i] = entry.Offset
+		}
+	}
+
+	structures, err := self.layoutStructures(layout)
+	if err != nil {
+		return err
+	}
+
+	free := _Complement(self.layoutDataIntervals(layout), layout.FileSize)
+
+	histogram := [256]uint64{}
+	if err := _ForEachChunk(self.reader, free, func(offset uint32, chunk []byte) error {
+		_, owners := _ExpectedBytes(offset, uint32(len(chunk)), 0, structures)
+		for i, b := range chunk {
+			if owners[i] != nil {
+				continue
+			}
+			histogram[b] += 1
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	paddingFill := byte(0)
+	for b := range histogram {
+		if histogram[b] > histogram[paddingFill] {
+			paddingFill = byte(b)
+		}
+	}
+
+	if err := _ForEachChunk(self.reader, free, func(offset uint32, chunk []byte) error {
+		expected, owners := _ExpectedBytes(offset, uint32(len(chunk)), paddingFill, structures)
+
+		// Gaps never hold header, entry table or attribute bytes of entries
+		// that are not null, which are written from the entries and would
+		// otherwise be overwritten by stale data after an edit.
+		var gap *Gap
+		for i, b := range chunk {
+			if owners[i] != nil && !owners[i].loose {
+				gap = nil
+				continue
+			}
+
+			if expected[i] == b {
+				continue
+			}
+
+			position := offset + uint32(i)
+			if gap != nil && position-(gap.Offset+uint32(len(gap.Data))) <= _LayoutGapMergeDistance {
+				gap.Data = append(gap.Data, chunk[gap.Offset+uint32(len(gap.Data))-offset:i+1]...)
+				continue
+			}
+
+			gap = &Gap{
+				Offset: position,
+				Data:   []byte{b},
+			}
+			layout.Gaps = append(layout.Gaps, gap)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	self.Layout = layout
+	self.PaddingFill = paddingFill
+
+	return nil
+}
+
+func (self *Afs) layoutStructures(layout *Layout) ([]*_Structure, error) {
+	entries := make([]*Entry, len(self.Entries))
+	for i, entry := range self.Entries {
+		entries[i] = &Entry{
+			Offset:        layout.Offsets[i],
+			Name:          entry.Name,
+			RawName:       entry.RawName,
+			Size:          entry.Size,
+			LastWriteTime: entry.LastWriteTime,
+			CustomData:    entry.CustomData,
+			IsNull:        entry.IsNull,
+		}
+	}
+
+	header := bytes.Buffer{}
+	if err := _WriteHeader(&header, self.Version, entries); err != nil {
+		return nil, err
+	}
+
+	structures := _SplitRows(0, header.Bytes(), HeaderSize, EntryInfoElementSize, entries)
+
+	if self.AttributesInfo == AttributesInfoNoAttribute {
+		return structures, nil
+	}
+
+	attributesInfoPosition := uint32(header.Len())
+	if self.AttributesInfo == AttributesInfoInfoAtEnd {
+		for _, entry := range entries {
+			if !entry.IsNull {
+				attributesInfoPosition = entry.Offset - AttributeInfoSize
+				break
+			}
+		}
+	}
+
+	attributesInfo := make([]byte, AttributeInfoSize)
+	binary.LittleEndian.PutUint32(attributesInfo, layout.AttributesOffset)
+	binary.LittleEndian.PutUint32(attributesInfo[4:], layout.AttributesSize)
+
+	attributes := bytes.Buffer{}
+	if err := _WriteAttributes(&attributes, entries, self.NameEncoding); err != nil {
+		return nil, err
+	}
+
+	structures = append(structures, &_Structure{offset: attributesInfoPosition, data: attributesInfo})
+	structures = append(structures, _SplitRows(layout.AttributesOffset, attributes.Bytes(), 0, AttributeElementSize, entries)...)
+
+	slices.SortStableFunc(structures, func(a, b *_Structure) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+
+	return structures, nil
+}
+
+// _SplitRows splits data at offset, a prefix followed by one row per entry,
+// into structures, joining neighbouring rows that are both loose or not.
+func _SplitRows(offset uint32, data []byte, prefix uint32, rowSize uint32, entries []*Entry) []*_Structure {
+	structures := []*_Structure{}
+
+	var last *_Structure
+	add := func(start uint32, end uint32, loose bool) {
+		if start >= end {
+			return
+		}
+
+		if last != nil && last.loose == loose && last.offset+uint32(len(last.data)) == offset+start {
+			last.data = data[last.offset-offset : end]
+			return
+		}
+
+		last = &_Structure{offset: offset + start, data: data[start:end], loose: loose}
+		structures = append(structures, last)
+	}
+
+	add(0, prefix, false)
+	for i, entry := range entries {
+		start := prefix + (uint32(i) * rowSize)
+		add(start, start+rowSize, entry.IsNull)
+	}
+
+	return structures
+}
+
+func (self *Afs) layoutDataIntervals(layout *Layout) []*_Interval {
+	intervals := []*_Interval{}
+	for i, entry := range self.Entries {
+		if entry.IsNull || entry.Size == 0 {
+			continue
+		}
+
+		start := layout.Offsets[i]
+		end := uint32(min(uint64(start)+uint64(entry.Size), uint64(layout.FileSize)))
+		if start >= end {
+			continue
+		}
+
+		intervals = append(intervals, &_Interval{start: start, end: end})
+	}
+
+	slices.SortFunc(intervals, func(a, b *_Interval) int {
+		return cmp.Compare(a.start, b.start)
+	})
+
+	return intervals
+}
+
+func (self *Afs) validateLayout() error {
+	layout := self.Layout
+
+	if len(layout.Offsets) != len(self.Entries) || uint32(len(self.Entries)) != self.EntryTotal {
+		return fmt.Errorf("%w, layout has %d entries, archive has %d", ErrLayoutMismatch, len(layout.Offsets), len(self.Entries))
+	}
+
+	reserved := []*_Interval{
+		{start: 0, end: HeaderSize + (self.EntryTotal * EntryInfoElementSize)},
+	}
+
+	if self.AttributesInfo != AttributesInfoNoAttribute {
+		attributesSize := self.EntryTotal * AttributeElementSize
+		if layout.AttributesSize < attributesSize {
+			return fmt.Errorf("%w, attribute table needs 0x%X bytes, layout has 0x%X", ErrLayoutMismatch, attributesSize, layout.AttributesSize)
+		}
+
+		reserved = append(reserved, &_Interval{start: layout.AttributesOffset, end: layout.AttributesOffset + layout.AttributesSize})
+		if self.AttributesInfo == AttributesInfoInfoAtStart {
+			reserved[0].end += AttributeInfoSize
+		}
+	}
+
+	for i, entry := range self.Entries {
+		if entry.IsNull {
+			if layout.Offsets[i] != 0 {
+				return _EntryError(i, entry, fmt.Errorf("%w, null entry has offset 0x%X", ErrLayoutMismatch, layout.Offsets[i]))
+			}
+			continue
+		}
+
+		start := uint64(layout.Offsets[i])
+		end := start + uint64(entry.Size)
+
+		if start == 0 || end > uint64(layout.FileSize) {
+			return _EntryError(i, entry, fmt.Errorf("%w, data 0x%X-0x%X does not fit in file size 0x%X", ErrLayoutMismatch, start, end, layout.FileSize))
+		}
+
+		for _, interval := range reserved {
+			if start < uint64(interval.end) && uint64(interval.start) < end {
+				return _EntryError(i, entry, fmt.Errorf("%w, data 0x%X-0x%X overlaps 0x%X-0x%X", ErrLayoutMismatch, start, end, interval.start, interval.end))
+			}
+		}
+	}
+
+	intervals := self.layoutDataIntervals(layout)
+	for i := 1; i < len(intervals); i++ {
+		if intervals[i-1].end > intervals[i].start {
+			return fmt.Errorf("%w, entry data overlaps at 0x%X", ErrLayoutMismatch, intervals[i].start)
+		}
+	}
+
+	return nil
+}
+
+func (self *Afs) packLayout(
+	ctx context.Context,
+	packFile *os.File,
+	workers int,
+	onStart,
+	onDone func(total uint32, current uint32, name string),
+) error {
+	layout := self.Layout
+
+	structures, err := self.layoutStructures(layout)
+	if err != nil {
+		return err
+	}
+
+	if err := packFile.Truncate(int64(layout.FileSize)); err != nil {
+		return err
+	}
+
+	if self.PaddingFill != 0 {
+		if err := _WriteFill(packFile, _Complement(self.layoutDataIntervals(layout), layout.FileSize), self.PaddingFill); err != nil {
+			return err
+		}
+	}
+
+	// Gaps go over the rows of null entries and under everything else, so an
+	// edit always wins over the original bytes.
+	if err := _WriteStructures(packFile, structures, true); err != nil {
+		return err
+	}
+
+	for _, gap := range layout.Gaps {
+		if _, err := packFile.WriteAt(gap.Data, int64(gap.Offset)); err != nil {
+			return err
+		}
+	}
+
+	if err := _WriteStructures(packFile, structures, false); err != nil {
+		return err
+	}
+
+	return _RunEntries(
+		ctx,
+		workers,
+		self.Entries,
+		self.EntryTotal,
+		nil,
+		onStart,
+		onDone,
+		func(i int, entry *Entry) error {
+			if entry.IsNull {
+				return nil
+			}
+
+			reader, err := entry.Open()
+			if err != nil {
+				return err
+			}
+			defer reader.Close()
+
+			return _CopyEntry(io.NewOffsetWriter(packFile, int64(layout.Offsets[i])), reader, entry)
+		},
+	)
+}
+
+func _WriteStructures(w io.WriterAt, structures []*_Structure, loose bool) error {
+	for _, structure := range structures {
+		if structure.loose != loose {
+			continue
+		}
+
+		if _, err := w.WriteAt(structure.data, int64(structure.offset)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func _Complement(intervals []*_Interval, size uint32) []*_Interval {
+	result := []*_Interval{}
+	position := uint32(0)
+
+	for _, interval := range intervals {
+		if interval.start > position {
+			result = append(result, &_Interval{start: position, end: interval.start})
+		}
+		position = max(position, interval.end)
+	}
+
+	if position < size {
+		result = append(result, &_Interval{start: position, end: size})
+	}
+
+	return result
+}
+
+func _ForEachChunk(reader io.ReaderAt, intervals []*_Interval, fn func(offset uint32, chunk []byte) error) error {
+	buf := make([]byte, _LayoutChunkSize)
+
+	for _, interval := range intervals {
+		for offset := interval.start; offset < interval.end; offset += _LayoutChunkSize {
+			chunk := buf[:min(interval.end-offset, _LayoutChunkSize)]
+			if _, err := reader.ReadAt(chunk, int64(offset)); err != nil {
+				return _ReadError("layout", err)
+			}
+
+			if err := fn(offset, chunk); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// _ExpectedBytes returns the bytes packing would write at offset from the
+// structures and the fill, and for each byte the structure it belongs to.
+// Structures must be sorted by offset.
+func _ExpectedBytes(offset uint32, size uint32, fill byte, structures []*_Structure) ([]byte, []*_Structure) {
+	expected := bytes.Repeat([]byte{fill}, int(size))
+	owners := make([]*_Structure, size)
+
+	end := uint64(offset) + uint64(size)
+	first, _ := slices.BinarySearchFunc(structures, uint64(offset), func(structure *_Structure, target uint64) int {
+		return cmp.Compare(uint64(structure.offset)+uint64(len(structure.data)), target+1)
+	})
+
+	for _, structure := range structures[first:] {
+		start := uint64(structure.offset)
+		stop := start + uint64(len(structure.data))
+		if start >= end {
+			break
+		}
+		if stop <= uint64(offset) {
+			continue
+		}
+
+		from := max(start, uint64(offset))
+		to := min(stop, end)
+		copy(expected[from-uint64(offset):to-uint64(offset)], structure.data[from-start:to-start])
+		for i := from; i < to; i++ {
+			owners[i-uint64(offset)] = structure
+		}
+	}
+
+	return expected, owners
+}
@@ -0,0 +1,169 @@
+package afs
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// _BuildLayoutTestArchive packs an archive with the attribute info at the end,
+// 0x80 alignment and 0xFF padding, then writes stray bytes into the rows of
+// its null entry and into its padding: right after the entry table, next to
+// the attribute info, after entry data and on both sides of the attribute
+// table.
+func _BuildLayoutTestArchive(t *testing.T) string {
+	t.Helper()
+
+	path := _BuildTestArchive(
+		t,
+		func(a *Afs) {
+			a.AttributesInfo = AttributesInfoInfoAtEnd
+			a.DataAlignment = 0x80
+			a.EndAlignment = 0x80
+			a.PaddingFill = 0xFF
+			a.EntryBlockAlignment = 0x100
+		},
+		[]_TestEntry{
+			{name: "first.bin", data: _TestData(1, 0x45)},
+			{null: true},
+			{name: "second.bin", data: _TestData(2, 0x90)},
+		},
+	)
+
+	a := _OpenTestArchive(t, path)
+	first := a.Entries[0]
+	second := a.Entries[2]
+
+	stray := map[int64][]byte{
+		int64(HeaderSize + EntryInfoElementSize + 4):     []byte{0x12, 0x34},
+		int64(HeaderSize + 3*EntryInfoElementSize):       []byte("garbage-garbage!"),
+		int64(first.Offset - 2*AttributeInfoSize):        []byte("GARBAGE!"),
+		int64(first.Offset + first.Size):                 []byte("garbage"),
+		int64(second.Offset + second.Size):               []byte("tail"),
+		int64(a.attributesOffset - 4):                    []byte("head"),
+		int64(a.attributesOffset + a.attributesSize):     []byte("end"),
+		int64(a.attributesOffset + AttributeElementSize): []byte("null record"),
+	}
+	a.Close()
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for offset, data := range stray {
+		if _, err := file.WriteAt(data, offset); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return path
+}
+
+func TestLayoutRoundTrip(t *testing.T) {
+	path := _BuildLayoutTestArchive(t)
+	want := _ReadTestFile(t, path)
+
+	for _, workers := range []int{1, 4} {
+		a := _OpenTestArchive(t, path)
+		if err := a.CaptureLayout(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(a.Layout.Gaps) == 0 {
+			t.Fatalf("layout has no gaps, the stray bytes were not found")
+		}
+
+		output := filepath.Join(t.TempDir(), "layout.afs")
+		if err := a.PackWithOptions(context.Background(), output, PackOptions{Workers: workers}, _TestProgress, _TestProgress); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(_ReadTestFile(t, output), want) {
+			t.Errorf("workers %d: packed archive is not byte identical", workers)
+		}
+	}
+}
+
+func TestLayoutGapsAvoidStructures(t *testing.T) {
+	a := _OpenTestArchive(t, _BuildLayoutTestArchive(t))
+	if err := a.CaptureLayout(); err != nil {
+		t.Fatal(err)
+	}
+
+	structures, err := a.layoutStructures(a.Layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, gap := range a.Layout.Gaps {
+		start := uint64(gap.Offset)
+		end := start + uint64(len(gap.Data))
+
+		for _, structure := range structures {
+			if structure.loose {
+				continue
+			}
+
+			structureStart := uint64(structure.offset)
+			structureEnd := structureStart + uint64(len(structure.data))
+
+			if start < structureEnd && structureStart < end {
+				t.Errorf("gap 0x%X-0x%X overlaps structure 0x%X-0x%X", start, end, structureStart, structureEnd)
+			}
+		}
+	}
+}
+
+func TestLayoutKeepsEdits(t *testing.T) {
+	path := _BuildLayoutTestArchive(t)
+	original := _ReadTestFile(t, path)
+
+	a := _OpenTestArchive(t, path)
+	if err := a.CaptureLayout(); err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp := Timestamp{Year: 2001, Month: 2, Day: 3, Hour: 4, Minute: 5, Second: 6}
+	for _, entry := range a.Entries {
+		if entry.IsNull {
+			continue
+		}
+		entry.Name = "renamed_" + entry.Name
+		entry.RawName = nil
+		entry.LastWriteTime = timestamp
+	}
+
+	shorter := _TestData(4, 0x50)
+	a.Entries[2].Data = NewBytesSource(shorter)
+	a.Entries[2].Size = uint32(len(shorter))
+	a.Entries[2].CustomData = uint32(len(shorter))
+
+	output := filepath.Join(t.TempDir(), "edited.afs")
+	if err := a.Pack(context.Background(), output, _TestProgress, _TestProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := _OpenTestArchive(t, output)
+	_CheckTestArchive(t, edited, []_TestEntry{
+		{name: "renamed_first.bin", data: _TestData(1, 0x45)},
+		{null: true},
+		{name: "renamed_second.bin", data: shorter},
+	})
+
+	for i, entry := range edited.Entries {
+		if !entry.IsNull && entry.LastWriteTime != timestamp {
+			t.Errorf("entry %d: last write time is %s, want %s", i, entry.LastWriteTime, timestamp)
+		}
+	}
+
+	for _, gap := range a.Layout.Gaps {
+		got := _ReadTestFile(t, output)[gap.Offset : gap.Offset+uint32(len(gap.Data))]
+		if !bytes.Equal(got, original[gap.Offset:gap.Offset+uint32(len(gap.Data))]) {
+			t.Errorf("gap at 0x%X was not kept", gap.Offset)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package afs
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"time"
 )
@@ -19,42 +21,84 @@ const (
 	HeaderSize                 uint32 = 0x8
 	EntryInfoElementSize       uint32 = 0x8
 	AlignmentSize              uint32 = 0x800
+	MaxDetectedAlignment       uint32 = 0x10000
 )
 
 type Afs struct {
 	Version             Version        `json:"version"`
 	AttributesInfo      AttributesInfo `json:"attributes_info"`
 	EntryBlockAlignment uint32         `json:"entry_block_alignment"`
+	DataAlignment       uint32         `json:"data_alignment"`
+	EndAlignment        uint32         `json:"end_alignment"`
+	PaddingFill         byte           `json:"padding_fill"`
+	NameEncoding        NameEncoding   `json:"name_encoding"`
 	EntryTotal          uint32         `json:"entry_total"`
 	Entries             []*Entry       `json:"entries"`
+	Layout              *Layout        `json:"layout,omitempty"`
+
+	closer           io.Closer
+	reader           io.ReaderAt
+	writer           io.WriterAt
+	size             int64
+	attributesOffset uint32
+	attributesSize   uint32
+
+	attributesInfoCandidates []*_AttributesInfoCandidate
+	lenient                  bool
 }
 
-func (self *Afs) unmarshal(source string, stream io.ReadWriteSeeker) error {
-	size, _ := stream.Seek(0, io.SeekEnd)
-	if _, err := stream.Seek(0, io.SeekStart); err != nil {
-		return err
+// _AttributesInfoCandidate is an attribute info read from one of the places
+// it can be stored, kept even when it does not point to an attribute table.
+type _AttributesInfoCandidate struct {
+	position uint32
+	offset   uint32
+	size     uint32
+}
+
+func _ReadAttributesInfoCandidate(stream io.ReadSeeker, position uint32) (*_AttributesInfoCandidate, error) {
+	candidate := &_AttributesInfoCandidate{position: position}
+
+	if _, err := stream.Seek(int64(position), io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Read(stream, binary.LittleEndian, &candidate.offset); err != nil {
+		return nil, _ReadError("attribute info", err)
+	}
+
+	if err := binary.Read(stream, binary.LittleEndian, &candidate.size); err != nil {
+		return nil, _ReadError("attribute info", err)
 	}
 
+	return candidate, nil
+}
+
+func (self *Afs) unmarshal(source string, reader io.ReaderAt, size int64) error {
+	stream := io.NewSectionReader(reader, 0, size)
+	self.reader = reader
+	self.size = size
+
 	var signature uint32
 	if err := binary.Read(stream, binary.LittleEndian, &signature); err != nil {
-		return err
+		return _ReadError("header", err)
 	}
 
 	if signature&0x00FFFFFF != Signature {
-		return fmt.Errorf("Invalid signature")
+		return ErrInvalidSignature
 	}
 
-	switch (signature & 0xFF000000) >> (3 * 8) {
+	version := (signature & 0xFF000000) >> (3 * 8)
+	switch version {
 	case 0x00:
 		self.Version = Version00
 	case 0x20:
 		self.Version = Version20
 	default:
-		return fmt.Errorf("Invalid version")
+		return fmt.Errorf("%w 0x%02X", ErrUnsupportedVersion, version)
 	}
 
 	if err := binary.Read(stream, binary.LittleEndian, &self.EntryTotal); err != nil {
-		return err
+		return _ReadError("header", err)
 	}
 
 	entryBlockStartOffset := uint32(0)
@@ -63,18 +107,26 @@ func (self *Afs) unmarshal(source string, stream io.ReadWriteSeeker) error {
 	for e := uint32(0); e < self.EntryTotal; e++ {
 		entry := &Entry{
 			Source: source,
+			reader: reader,
 		}
-		self.Entries = append(self.Entries, entry)
 
 		if err := binary.Read(stream, binary.LittleEndian, &entry.Offset); err != nil {
-			return err
+			if self.lenient {
+				break
+			}
+			return _EntryError(int(e), entry, _ReadError("entry table", err))
 		}
 		entry.IsNull = entry.Offset == 0
 
 		if err := binary.Read(stream, binary.LittleEndian, &entry.Size); err != nil {
-			return err
+			if self.lenient {
+				break
+			}
+			return _EntryError(int(e), entry, _ReadError("entry table", err))
 		}
 
+		self.Entries = append(self.Entries, entry)
+
 		if entry.IsNull {
 			continue
 		}
@@ -85,7 +137,7 @@ func (self *Afs) unmarshal(source string, stream io.ReadWriteSeeker) error {
 		entryBlockEndOffset = entry.Offset + entry.Size
 	}
 
-	position, _ := stream.Seek(0, io.SeekCurrent)
+	position := int64(HeaderSize) + int64(len(self.Entries))*int64(EntryInfoElementSize)
 
 	alignment := MinEntryBlockAlignmentSize
 	endInfoBlockOffset := uint32(position) + AttributeInfoSize
@@ -97,64 +149,67 @@ func (self *Afs) unmarshal(source string, stream io.ReadWriteSeeker) error {
 
 	self.AttributesInfo = AttributesInfoNoAttribute
 
+	self.attributesInfoCandidates = []*_AttributesInfoCandidate{}
+
 	var (
 		attributeDataOffset uint32
 		attributeDataSize   uint32
 	)
 
-	if err := binary.Read(stream, binary.LittleEndian, &attributeDataOffset); err != nil {
-		return err
-	}
-
-	if err := binary.Read(stream, binary.LittleEndian, &attributeDataSize); err != nil {
-		return err
-	}
-
-	isAttributeInfoValid := _IsAttributeInfoValid(attributeDataOffset, attributeDataSize, uint32(size), self.EntryTotal, entryBlockEndOffset)
-
-	if isAttributeInfoValid {
-		self.AttributesInfo = AttributesInfoInfoAtStart
-	} else {
-		if _, err := stream.Seek(int64(entryBlockStartOffset-AttributeInfoSize), io.SeekStart); err != nil {
-			return err
-		}
-
-		if err := binary.Read(stream, binary.LittleEndian, &attributeDataOffset); err != nil {
+	candidate, err := _ReadAttributesInfoCandidate(stream, uint32(position))
+	if err != nil {
+		if !self.lenient {
 			return err
 		}
+	} else {
+		self.attributesInfoCandidates = append(self.attributesInfoCandidates, candidate)
+	}
 
-		if err := binary.Read(stream, binary.LittleEndian, &attributeDataSize); err != nil {
-			return err
+	if candidate != nil && _IsAttributeInfoValid(candidate.offset, candidate.size, uint32(size), self.EntryTotal, entryBlockEndOffset) {
+		self.AttributesInfo = AttributesInfoInfoAtStart
+		attributeDataOffset = candidate.offset
+		attributeDataSize = candidate.size
+	} else if entryBlockStartOffset > uint32(position)+AttributeInfoSize {
+		candidate, err := _ReadAttributesInfoCandidate(stream, entryBlockStartOffset-AttributeInfoSize)
+		if err != nil {
+			if !self.lenient {
+				return err
+			}
+		} else {
+			self.attributesInfoCandidates = append(self.attributesInfoCandidates, candidate)
 		}
 
-		isAttributeInfoValid = _IsAttributeInfoValid(attributeDataOffset, attributeDataSize, uint32(size), self.EntryTotal, entryBlockEndOffset)
-
-		if isAttributeInfoValid {
+		if candidate != nil && _IsAttributeInfoValid(candidate.offset, candidate.size, uint32(size), self.EntryTotal, entryBlockEndOffset) {
 			self.AttributesInfo = AttributesInfoInfoAtEnd
+			attributeDataOffset = candidate.offset
+			attributeDataSize = candidate.size
 		}
 	}
 
 	if self.AttributesInfo != AttributesInfoNoAttribute {
+		self.attributesOffset = attributeDataOffset
+		self.attributesSize = attributeDataSize
+
 		if _, err := stream.Seek(int64(attributeDataOffset), io.SeekStart); err != nil {
 			return err
 		}
 
-		for _, entry := range self.Entries {
+		for i, entry := range self.Entries {
 			if entry.IsNull {
 
 				if _, err := stream.Seek(int64(AttributeElementSize), io.SeekCurrent); err != nil {
-					return err
+					return _EntryError(i, entry, _ReadError("attribute table", err))
 				}
 				continue
 
 			} else {
 
 				name := make([]byte, MaxEntryNameLength)
-				if _, err := stream.Read(name); err != nil {
-					return err
+				if _, err := io.ReadFull(stream, name); err != nil {
+					return _EntryError(i, entry, _ReadError("attribute table", err))
 				}
 
-				entry.Name = _StringFromBytes(name)
+				entry.RawName = bytes.TrimRight(name, "\x00")
 
 				var (
 					year       uint16
@@ -167,45 +222,66 @@ func (self *Afs) unmarshal(source string, stream io.ReadWriteSeeker) error {
 				)
 
 				if err := binary.Read(stream, binary.LittleEndian, &year); err != nil {
-					return err
+					return _EntryError(i, entry, _ReadError("attribute table", err))
 				}
 
 				if err := binary.Read(stream, binary.LittleEndian, &month); err != nil {
-					return err
+					return _EntryError(i, entry, _ReadError("attribute table", err))
 				}
 
 				if err := binary.Read(stream, binary.LittleEndian, &day); err != nil {
-					return err
+					return _EntryError(i, entry, _ReadError("attribute table", err))
 				}
 
 				if err := binary.Read(stream, binary.LittleEndian, &hour); err != nil {
-					return err
+					return _EntryError(i, entry, _ReadError("attribute table", err))
 				}
 
 				if err := binary.Read(stream, binary.LittleEndian, &minute); err != nil {
-					return err
+					return _EntryError(i, entry, _ReadError("attribute table", err))
 				}
 
 				if err := binary.Read(stream, binary.LittleEndian, &second); err != nil {
-					return err
+					return _EntryError(i, entry, _ReadError("attribute table", err))
 				}
 
 				if err := binary.Read(stream, binary.LittleEndian, &customData); err != nil {
-					return err
+					return _EntryError(i, entry, _ReadError("attribute table", err))
 				}
 
-				entry.LastWriteTime = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, minute, second)
+				entry.LastWriteTime = Timestamp{
+					Year:   year,
+					Month:  month,
+					Day:    day,
+					Hour:   hour,
+					Minute: minute,
+					Second: second,
+				}
 				entry.CustomData = customData
 
 			}
 		}
+
+		raws := [][]byte{}
+		for _, entry := range self.Entries {
+			if entry.RawName != nil {
+				raws = append(raws, entry.RawName)
+			}
+		}
+		self.SetNameEncoding(DetectNameEncoding(raws))
 	} else {
 		for i, entry := range self.Entries {
 			entry.Name = fmt.Sprintf("%08d", i)
 		}
 	}
 
-	return nil
+	return self.detectPadding()
+}
+
+type PackOptions struct {
+	// Workers is the number of entries read and written concurrently. Output
+	// is identical for any worker count; callbacks behave as in UnpackOptions.
+	Workers int
 }
 
 func (self *Afs) Pack(
@@ -214,194 +290,223 @@ func (self *Afs) Pack(
 	onStart,
 	onDone func(total uint32, current uint32, name string),
 ) error {
+	return self.PackWithOptions(ctx, output, PackOptions{Workers: 1}, onStart, onDone)
+}
+
+func (self *Afs) PackWithOptions(
+	ctx context.Context,
+	output string,
+	options PackOptions,
+	onStart,
+	onDone func(total uint32, current uint32, name string),
+) error {
+	for i, entry := range self.Entries {
+		if err := _ValidateEntry(entry, self.AttributesInfo, self.NameEncoding); err != nil {
+			return _EntryError(i, entry, err)
+		}
+	}
+
+	if err := _ValidateAlignment(self.DataAlignment, self.EndAlignment); err != nil {
+		return err
+	}
+
+	if self.Layout != nil {
+		if err := self.validateLayout(); err != nil {
+			return err
+		}
+	}
+
 	packFile, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
 	defer packFile.Close()
 
-	if err := binary.Write(packFile, binary.LittleEndian, Signature); err != nil {
-		return err
+	if self.Layout != nil {
+		if err := self.packLayout(ctx, packFile, max(options.Workers, 1), onStart, onDone); err != nil {
+			return err
+		}
+		return packFile.Close()
 	}
 
-	if _, err := packFile.Seek(-1, io.SeekCurrent); err != nil {
-		return err
+	if options.Workers > 1 {
+		if err := self.packConcurrent(ctx, packFile, options.Workers, onStart, onDone); err != nil {
+			return err
+		}
+		return packFile.Close()
 	}
 
-	if err := binary.Write(packFile, binary.LittleEndian, self.Version); err != nil {
+	writer := NewWriter(packFile, self.EntryTotal)
+	writer.Version = self.Version
+	writer.AttributesInfo = self.AttributesInfo
+	writer.EntryBlockAlignment = self.EntryBlockAlignment
+	writer.DataAlignment = self.DataAlignment
+	writer.EndAlignment = self.EndAlignment
+	writer.PaddingFill = self.PaddingFill
+	writer.NameEncoding = self.NameEncoding
+
+	if err := _RunEntries(
+		ctx,
+		1,
+		self.Entries,
+		self.EntryTotal,
+		nil,
+		onStart,
+		onDone,
+		func(i int, entry *Entry) error {
+			return self.packEntry(writer, entry)
+		},
+	); err != nil {
 		return err
 	}
 
-	if err := binary.Write(packFile, binary.LittleEndian, self.EntryTotal); err != nil {
+	if err := writer.Close(); err != nil {
 		return err
 	}
 
-	offsets := []uint32{}
+	return packFile.Close()
+}
 
-	firstEntryOffset := _Pad(
-		HeaderSize+(EntryInfoElementSize*self.EntryTotal)+AttributeInfoSize,
-		self.EntryBlockAlignment,
-	)
-	currentEntryOffset := firstEntryOffset
+func (self *Afs) packConcurrent(
+	ctx context.Context,
+	packFile *os.File,
+	workers int,
+	onStart,
+	onDone func(total uint32, current uint32, name string),
+) error {
+	if uint32(len(self.Entries)) != self.EntryTotal {
+		return fmt.Errorf("%w, expected %d entries, got %d", ErrEntryTotalMismatch, self.EntryTotal, len(self.Entries))
+	}
 
-	for _, entry := range self.Entries {
-		if entry.IsNull {
-			offsets = append(offsets, 0)
-		} else {
-			offsets = append(offsets, currentEntryOffset)
-			currentEntryOffset += entry.Size
-			currentEntryOffset = _Pad(currentEntryOffset, AlignmentSize)
-		}
+	if self.EntryBlockAlignment == 0 {
+		return fmt.Errorf("%w, entry block alignment is 0", ErrInvalidAlignment)
 	}
 
+	packed := make([]*Entry, 0, len(self.Entries))
+	position := _FirstEntryOffset(self.EntryTotal, self.EntryBlockAlignment)
+
 	for i, entry := range self.Entries {
 		if entry.IsNull {
-			if err := binary.Write(packFile, binary.LittleEndian, uint32(0)); err != nil {
-				return err
-			}
-			if err := binary.Write(packFile, binary.LittleEndian, uint32(0)); err != nil {
-				return err
-			}
-		} else {
-			if err := binary.Write(packFile, binary.LittleEndian, offsets[i]); err != nil {
-				return err
-			}
-			if err := binary.Write(packFile, binary.LittleEndian, entry.Size); err != nil {
-				return err
-			}
+			packed = append(packed, &Entry{IsNull: true})
+			continue
+		}
+
+		if uint64(position)+uint64(entry.Size) > math.MaxUint32 {
+			return _EntryError(i, entry, ErrArchiveTooLarge)
 		}
+
+		packed = append(
+			packed,
+			&Entry{
+				Offset:        position,
+				Name:          entry.Name,
+				RawName:       entry.RawName,
+				Size:          entry.Size,
+				LastWriteTime: entry.LastWriteTime,
+				CustomData:    entry.CustomData,
+			},
+		)
+		position = _Pad(position+entry.Size, self.DataAlignment)
 	}
 
-	position, err := packFile.Seek(int64(HeaderSize+(self.EntryTotal*EntryInfoElementSize)), io.SeekStart)
-	if err != nil {
-		return err
+	attributesOffset := position
+	end := position
+	if self.AttributesInfo != AttributesInfoNoAttribute {
+		end += self.EntryTotal * AttributeElementSize
 	}
 
-	if _, err := packFile.Write(make([]byte, firstEntryOffset-uint32(position))); err != nil {
+	fileSize := _Pad(end, self.EndAlignment)
+	if err := packFile.Truncate(int64(fileSize)); err != nil {
 		return err
 	}
 
-	attributesInfoOffset := currentEntryOffset
-
-	if self.AttributesInfo != AttributesInfoNoAttribute {
-		if self.AttributesInfo == AttributesInfoInfoAtStart {
-			if _, err := packFile.Seek(int64(HeaderSize+(self.EntryTotal*EntryInfoElementSize)), io.SeekStart); err != nil {
-				return err
-			}
-		} else if self.AttributesInfo == AttributesInfoInfoAtEnd {
-			if _, err := packFile.Seek(int64(firstEntryOffset-AttributeInfoSize), io.SeekStart); err != nil {
-				return err
+	if self.PaddingFill != 0 {
+		intervals := []*_Interval{}
+		for _, entry := range packed {
+			if !entry.IsNull && entry.Size != 0 {
+				intervals = append(intervals, &_Interval{start: entry.Offset, end: entry.Offset + entry.Size})
 			}
 		}
 
-		if err := binary.Write(packFile, binary.LittleEndian, attributesInfoOffset); err != nil {
-			return err
-		}
-		if err := binary.Write(packFile, binary.LittleEndian, self.EntryTotal*AttributeElementSize); err != nil {
+		if err := _WriteFill(packFile, _Complement(intervals, fileSize), self.PaddingFill); err != nil {
 			return err
 		}
 	}
 
-	for i, entry := range self.Entries {
-		onStart(self.EntryTotal, uint32(i+1), entry.Name)
-		if !entry.IsNull {
-			if _, err := packFile.Seek(int64(offsets[i]), io.SeekStart); err != nil {
-				return err
-			}
-
-			entryFile, err := os.Open(entry.Source)
-			if err != nil {
-				return err
-			}
-			defer entryFile.Close()
-
-			buf := make([]byte, entry.Size)
-
-			if _, err := entryFile.Read(buf); err != nil {
-				return err
-			}
-
-			if _, err := packFile.Write(buf); err != nil {
-				return err
-			}
-		}
-		onDone(self.EntryTotal, uint32(i+1), entry.Name)
+	header, err := _EncodeHeader(self.Version, self.AttributesInfo, self.EntryBlockAlignment, self.PaddingFill, packed, attributesOffset)
+	if err != nil {
+		return err
+	}
 
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("Canceled")
-		default:
-		}
+	if _, err := packFile.WriteAt(header, 0); err != nil {
+		return err
 	}
 
 	if self.AttributesInfo != AttributesInfoNoAttribute {
-		if _, err := packFile.Seek(int64(attributesInfoOffset), io.SeekStart); err != nil {
+		var attributes bytes.Buffer
+		if err := _WriteAttributes(&attributes, packed, self.NameEncoding); err != nil {
 			return err
 		}
 
-		for _, entry := range self.Entries {
-			if entry.IsNull {
-				if _, err := packFile.Seek(int64(AttributeElementSize), io.SeekCurrent); err != nil {
-					return err
-				}
-			} else {
-				buf := []byte(entry.Name)
-
-				if _, err := packFile.Write(buf); err != nil {
-					return err
-				}
-
-				if _, err := packFile.Seek(int64(MaxEntryNameLength-uint32(len(entry.Name))), io.SeekCurrent); err != nil {
-					return err
-				}
-
-				lastWrite, err := time.Parse(DateLayoutFormat, entry.LastWriteTime)
-				if err != nil {
-					return err
-				}
-
-				if err := binary.Write(packFile, binary.LittleEndian, uint16(lastWrite.Year())); err != nil {
-					return err
-				}
-
-				if err := binary.Write(packFile, binary.LittleEndian, uint16(lastWrite.Month())); err != nil {
-					return err
-				}
-
-				if err := binary.Write(packFile, binary.LittleEndian, uint16(lastWrite.Day())); err != nil {
-					return err
-				}
-
-				if err := binary.Write(packFile, binary.LittleEndian, uint16(lastWrite.Hour())); err != nil {
-					return err
-				}
+		if _, err := packFile.WriteAt(attributes.Bytes(), int64(attributesOffset)); err != nil {
+			return err
+		}
+	}
 
-				if err := binary.Write(packFile, binary.LittleEndian, uint16(lastWrite.Minute())); err != nil {
-					return err
-				}
+	return _RunEntries(
+		ctx,
+		workers,
+		self.Entries,
+		self.EntryTotal,
+		nil,
+		onStart,
+		onDone,
+		func(i int, entry *Entry) error {
+			if entry.IsNull {
+				return nil
+			}
 
-				if err := binary.Write(packFile, binary.LittleEndian, uint16(lastWrite.Second())); err != nil {
-					return err
-				}
+			reader, err := entry.Open()
+			if err != nil {
+				return err
+			}
+			defer reader.Close()
 
-				if err := binary.Write(packFile, binary.LittleEndian, entry.CustomData); err != nil {
-					return err
-				}
+			return _CopyEntry(io.NewOffsetWriter(packFile, int64(packed[i].Offset)), reader, entry)
+		},
+	)
+}
 
-			}
-		}
+func (self *Afs) packEntry(writer *Writer, entry *Entry) error {
+	if entry.IsNull {
+		return writer.AddNullEntry()
 	}
 
-	{
-		currentPosition, _ := packFile.Seek(0, io.SeekCurrent)
-		endOfFile := _Pad(uint32(currentPosition), AlignmentSize)
-
-		if _, err := packFile.Write(make([]byte, endOfFile-uint32(currentPosition))); err != nil {
-			return err
-		}
+	reader, err := entry.Open()
+	if err != nil {
+		return err
 	}
+	defer reader.Close()
 
-	return nil
+	return writer.writeEntry(
+		&Entry{
+			Name:          entry.Name,
+			RawName:       entry.RawName,
+			Size:          entry.Size,
+			LastWriteTime: entry.LastWriteTime,
+			CustomData:    entry.CustomData,
+		},
+		reader,
+	)
+}
+
+type UnpackOptions struct {
+	// Workers is the number of entries extracted concurrently. Callbacks are
+	// never called concurrently, but with more than one worker they may arrive
+	// out of entry order; current is always the entry index plus one.
+	Workers int
+	// Filter, when set, extracts only the entries it returns true for.
+	Filter func(index int, entry *Entry) bool
 }
 
 func (self *Afs) Unpack(
@@ -410,50 +515,62 @@ func (self *Afs) Unpack(
 	onStart,
 	onDone func(total uint32, current uint32, name string),
 ) error {
-	total := len(self.Entries)
-	for i, entry := range self.Entries {
-		if entry.IsNull {
-			continue
-		}
-
-		onStart(uint32(total), uint32(i+1), entry.Name)
-
-		sourceFile, err := os.Open(entry.Source)
-		if err != nil {
-			return err
-		}
-		defer sourceFile.Close()
+	return self.UnpackWithOptions(ctx, dir, UnpackOptions{Workers: 1}, onStart, onDone)
+}
 
-		if _, err := sourceFile.Seek(int64(entry.Offset), io.SeekStart); err != nil {
-			return err
-		}
+func (self *Afs) UnpackWithOptions(
+	ctx context.Context,
+	dir string,
+	options UnpackOptions,
+	onStart,
+	onDone func(total uint32, current uint32, name string),
+) error {
+	names := UniqueNames(self.Entries)
 
-		b := make([]byte, entry.Size)
+	return _RunEntries(
+		ctx,
+		options.Workers,
+		self.Entries,
+		uint32(len(self.Entries)),
+		func(i int, entry *Entry) bool {
+			return entry.IsNull || (options.Filter != nil && !options.Filter(i, entry))
+		},
+		onStart,
+		onDone,
+		func(i int, entry *Entry) error {
+			return self.unpackEntry(dir, names[i], entry)
+		},
+	)
+}
 
-		if _, err := sourceFile.Read(b); err != nil {
-			return err
-		}
+// unpackEntry writes an entry to dir under name, which must come from
+// UniqueNames so that an entry name such as ../x cannot leave dir.
+func (self *Afs) unpackEntry(dir string, name string, entry *Entry) error {
+	entryReader, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer entryReader.Close()
 
-		unpackFile, err := os.OpenFile(fmt.Sprintf("%s/%s", dir, entry.Name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-		if err != nil {
-			return err
-		}
-		defer unpackFile.Close()
+	unpackFile, err := os.OpenFile(fmt.Sprintf("%s/%s", dir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
 
-		if _, err := unpackFile.Write(b); err != nil {
-			return err
-		}
+	if err := _CopyEntry(unpackFile, entryReader, entry); err != nil {
+		unpackFile.Close()
+		return err
+	}
 
-		onDone(uint32(total), uint32(i+1), entry.Name)
+	return unpackFile.Close()
+}
 
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("Canceled")
-		default:
-		}
+func (self *Afs) OpenEntry(index int) (io.ReadCloser, error) {
+	if index < 0 || index >= len(self.Entries) {
+		return nil, fmt.Errorf("%w: %d", ErrEntryOutOfRange, index)
 	}
 
-	return nil
+	return self.Entries[index].Open()
 }
 
 func (self *Afs) AddNullEntry(name string) {
@@ -464,7 +581,7 @@ func (self *Afs) AddNullEntry(name string) {
 			Offset:        0,
 			Name:          name,
 			Size:          0,
-			LastWriteTime: "2000-01-01 00:00:00",
+			LastWriteTime: Timestamp{Year: 2000, Month: 1, Day: 1},
 			CustomData:    0,
 			IsNull:        true,
 		},
@@ -473,11 +590,39 @@ func (self *Afs) AddNullEntry(name string) {
 	self.EntryTotal += 1
 }
 
+func (self *Afs) AddEntryFromSource(source EntrySource, name string, lastWriteTime Timestamp) error {
+	size := source.Size()
+	if size < 0 || size > math.MaxUint32 {
+		return fmt.Errorf("%w, entry %s has %d bytes", ErrEntrySizeOutOfRange, name, size)
+	}
+
+	entry := &Entry{
+		Source:        "",
+		Offset:        0,
+		Name:          name,
+		Size:          uint32(size),
+		LastWriteTime: lastWriteTime,
+		CustomData:    uint32(size),
+		IsNull:        false,
+		Data:          source,
+	}
+
+	if err := _ValidateEntry(entry, self.AttributesInfo, self.NameEncoding); err != nil {
+		return _EntryError(len(self.Entries), entry, err)
+	}
+
+	self.Entries = append(self.Entries, entry)
+
+	self.EntryTotal += 1
+
+	return nil
+}
+
 func (self *Afs) AddEntryFromPath(source string) error {
 	return self.AddEntryFromPathWithNameLastWriteTime(
 		source,
 		_Basename(source),
-		time.Now().Format(DateLayoutFormat),
+		NewTimestamp(time.Now()),
 	)
 }
 
@@ -485,11 +630,11 @@ func (self *Afs) AddEntryFromPathWithName(source string, name string) error {
 	return self.AddEntryFromPathWithNameLastWriteTime(
 		source,
 		name,
-		time.Now().Format(DateLayoutFormat),
+		NewTimestamp(time.Now()),
 	)
 }
 
-func (self *Afs) AddEntryFromPathWithNameLastWriteTime(source string, name string, lastWriteTime string) error {
+func (self *Afs) AddEntryFromPathWithNameLastWriteTime(source string, name string, lastWriteTime Timestamp) error {
 	file, err := os.Open(source)
 	if err != nil {
 		return err
@@ -501,18 +646,21 @@ func (self *Afs) AddEntryFromPathWithNameLastWriteTime(source string, name strin
 		return err
 	}
 
-	self.Entries = append(
-		self.Entries,
-		&Entry{
-			Source:        source,
-			Offset:        0,
-			Name:          name,
-			Size:          uint32(size),
-			LastWriteTime: lastWriteTime,
-			CustomData:    uint32(size),
-			IsNull:        size == 0,
-		},
-	)
+	entry := &Entry{
+		Source:        source,
+		Offset:        0,
+		Name:          name,
+		Size:          uint32(size),
+		LastWriteTime: lastWriteTime,
+		CustomData:    uint32(size),
+		IsNull:        false,
+	}
+
+	if err := _ValidateEntry(entry, self.AttributesInfo, self.NameEncoding); err != nil {
+		return _EntryError(len(self.Entries), entry, err)
+	}
+
+	self.Entries = append(self.Entries, entry)
 
 	self.EntryTotal += 1
 
@@ -524,6 +672,9 @@ func New() *Afs {
 		Version:             Version00,
 		AttributesInfo:      AttributesInfoInfoAtStart,
 		EntryBlockAlignment: 0x800,
+		DataAlignment:       AlignmentSize,
+		EndAlignment:        AlignmentSize,
+		PaddingFill:         0,
 		EntryTotal:          0,
 		Entries:             []*Entry{},
 	}
@@ -531,6 +682,23 @@ func New() *Afs {
 	return &result
 }
 
+func (self *Afs) Close() error {
+	if self.closer == nil {
+		return nil
+	}
+
+	err := self.closer.Close()
+	self.closer = nil
+	self.reader = nil
+	self.writer = nil
+
+	for _, entry := range self.Entries {
+		entry.reader = nil
+	}
+
+	return err
+}
+
 func FromPath(afs *Afs, filePath string) error {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -538,5 +706,62 @@ func FromPath(afs *Afs, filePath string) error {
 	}
 	defer file.Close()
 
-	return afs.unmarshal(filePath, file)
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := afs.unmarshal(filePath, file, info.Size()); err != nil {
+		return err
+	}
+
+	afs.reader = nil
+	for _, entry := range afs.Entries {
+		entry.reader = nil
+	}
+
+	return nil
+}
+
+func Open(reader io.ReaderAt, size int64) (*Afs, error) {
+	result := New()
+	if err := result.unmarshal("", reader, size); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func OpenFile(filePath string) (*Afs, error) {
+	return _OpenFile(filePath, false)
+}
+
+// OpenFileLenient opens an archive whose entry table is cut short by the end
+// of the file, keeping the rows the file holds and the entry total from the
+// header so Verify can report the mismatch.
+func OpenFileLenient(filePath string) (*Afs, error) {
+	return _OpenFile(filePath, true)
+}
+
+func _OpenFile(filePath string, lenient bool) (*Afs, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	result := New()
+	result.lenient = lenient
+	if err := result.unmarshal(filePath, file, info.Size()); err != nil {
+		file.Close()
+		return nil, err
+	}
+	result.closer = file
+
+	return result, nil
 }
@@ -0,0 +1,279 @@
+package afs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type _TestEntry struct {
+	name string
+	data []byte
+	null bool
+}
+
+func _TestProgress(total uint32, current uint32, name string) {}
+
+var _TestTimestamp = Timestamp{Year: 2024, Month: 5, Day: 6, Hour: 7, Minute: 8, Second: 9}
+
+// _TestData returns size bytes that differ between seeds, so misplaced entry
+// data does not compare equal by accident.
+func _TestData(seed int, size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(seed*31 + i*7 + i/251)
+	}
+	return data
+}
+
+func _TestEntries() []_TestEntry {
+	return []_TestEntry{
+		{name: "first.bin", data: _TestData(1, 3000)},
+		{null: true},
+		{name: "empty.bin", data: []byte{}},
+		{name: "second.bin", data: _TestData(2, 0x801)},
+		{name: "third.bin", data: _TestData(3, 17)},
+	}
+}
+
+// _BuildTestArchive packs entries into a temporary file, after setup changes
+// the header fields of the new archive, and returns the file path.
+func _BuildTestArchive(t *testing.T, setup func(a *Afs), entries []_TestEntry) string {
+	t.Helper()
+
+	a := New()
+	if setup != nil {
+		setup(a)
+	}
+
+	for _, entry := range entries {
+		if entry.null {
+			a.AddNullEntry("")
+			continue
+		}
+
+		if err := a.AddEntryFromSource(NewBytesSource(entry.data), entry.name, _TestTimestamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	output := filepath.Join(t.TempDir(), "test.afs")
+	if err := a.PackWithOptions(context.Background(), output, PackOptions{Workers: 1}, _TestProgress, _TestProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	return output
+}
+
+func _OpenTestArchive(t *testing.T, path string) *Afs {
+	t.Helper()
+
+	a, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+
+	return a
+}
+
+func _ReadTestEntry(t *testing.T, entry *Entry) []byte {
+	t.Helper()
+
+	reader, err := entry.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func _ReadTestFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// _CheckTestArchive fails unless a holds entries and Verify finds no errors.
+func _CheckTestArchive(t *testing.T, a *Afs, entries []_TestEntry) {
+	t.Helper()
+
+	if report := Verify(a); report.HasErrors() {
+		for _, issue := range report.Issues {
+			t.Errorf("verify: %s entry %d: %s", issue.Severity, issue.Entry, issue.Message)
+		}
+	}
+
+	if len(a.Entries) != len(entries) || a.EntryTotal != uint32(len(entries)) {
+		t.Fatalf("got %d entries (entry total %d), want %d", len(a.Entries), a.EntryTotal, len(entries))
+	}
+
+	for i, want := range entries {
+		entry := a.Entries[i]
+
+		if entry.IsNull != want.null {
+			t.Errorf("entry %d: null is %t, want %t", i, entry.IsNull, want.null)
+			continue
+		}
+
+		if want.null {
+			continue
+		}
+
+		if a.AttributesInfo != AttributesInfoNoAttribute && entry.Name != want.name {
+			t.Errorf("entry %d: name is %q, want %q", i, entry.Name, want.name)
+		}
+
+		if data := _ReadTestEntry(t, entry); !bytes.Equal(data, want.data) {
+			t.Errorf("entry %d (%s): data differs, got %d bytes, want %d", i, want.name, len(data), len(want.data))
+		}
+	}
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	for _, attributesInfo := range []AttributesInfo{AttributesInfoNoAttribute, AttributesInfoInfoAtStart, AttributesInfoInfoAtEnd} {
+		t.Run(attributesInfo.String(), func(t *testing.T) {
+			entries := _TestEntries()
+			path := _BuildTestArchive(t, func(a *Afs) { a.AttributesInfo = attributesInfo }, entries)
+
+			a := _OpenTestArchive(t, path)
+			if a.AttributesInfo != attributesInfo {
+				t.Fatalf("attributes info is %s, want %s", a.AttributesInfo, attributesInfo)
+			}
+
+			_CheckTestArchive(t, a, entries)
+
+			if !a.Entries[2].IsEmpty() {
+				t.Errorf("empty entry is not kept apart from null entries")
+			}
+
+			if attributesInfo == AttributesInfoNoAttribute {
+				return
+			}
+
+			for i, entry := range a.Entries {
+				if entry.IsNull {
+					continue
+				}
+				if entry.LastWriteTime != _TestTimestamp {
+					t.Errorf("entry %d: last write time is %s, want %s", i, entry.LastWriteTime, _TestTimestamp)
+				}
+				if entry.CustomData != entry.Size {
+					t.Errorf("entry %d: custom data is %d, want the size %d", i, entry.CustomData, entry.Size)
+				}
+			}
+		})
+	}
+}
+
+func TestPackWorkersIdentical(t *testing.T) {
+	entries := []_TestEntry{}
+	for i := 0; i < 40; i++ {
+		entries = append(entries, _TestEntry{name: fmt.Sprintf("%02d.bin", i), data: _TestData(i, i*97)})
+	}
+
+	a := _OpenTestArchive(t, _BuildTestArchive(t, nil, entries))
+	want := _ReadTestFile(t, a.Entries[0].Source)
+
+	output := filepath.Join(t.TempDir(), "workers.afs")
+	if err := a.PackWithOptions(context.Background(), output, PackOptions{Workers: 8}, _TestProgress, _TestProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(_ReadTestFile(t, output), want) {
+		t.Errorf("packing with 8 workers differs from packing with 1")
+	}
+}
+
+func TestUnpackRoundTrip(t *testing.T) {
+	entries := _TestEntries()
+	path := _BuildTestArchive(t, nil, entries)
+	a := _OpenTestArchive(t, path)
+
+	dir := t.TempDir()
+	if err := a.UnpackWithOptions(context.Background(), dir, UnpackOptions{Workers: 4}, _TestProgress, _TestProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	names := UniqueNames(a.Entries)
+
+	repacked := New()
+	for i, entry := range a.Entries {
+		if entry.IsNull {
+			repacked.AddNullEntry(entry.Name)
+			continue
+		}
+
+		source := filepath.Join(dir, names[i])
+		if data := _ReadTestFile(t, source); !bytes.Equal(data, entries[i].data) {
+			t.Errorf("unpacked %s differs", names[i])
+		}
+
+		if err := repacked.AddEntryFromPathWithNameLastWriteTime(source, entry.Name, entry.LastWriteTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	output := filepath.Join(t.TempDir(), "repacked.afs")
+	if err := repacked.Pack(context.Background(), output, _TestProgress, _TestProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(_ReadTestFile(t, output), _ReadTestFile(t, path)) {
+		t.Errorf("packing the unpacked files does not reproduce the archive")
+	}
+}
+
+func TestUnpackFilter(t *testing.T) {
+	a := _OpenTestArchive(t, _BuildTestArchive(t, nil, _TestEntries()))
+
+	dir := t.TempDir()
+	options := UnpackOptions{
+		Workers: 2,
+		Filter: func(index int, entry *Entry) bool {
+			return index == 3
+		},
+	}
+	if err := a.UnpackWithOptions(context.Background(), dir, options, _TestProgress, _TestProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].Name() != "second.bin" {
+		t.Errorf("filter extracted %v, want only second.bin", files)
+	}
+}
+
+func TestUniqueNames(t *testing.T) {
+	entries := []*Entry{}
+	for _, name := range []string{"a.bin", "a.bin", "a_1.bin", "", ".", "..", "../up.bin", "dir/b.bin", "..\\w.bin", "a.bin"} {
+		entries = append(entries, &Entry{Name: name})
+	}
+
+	got := UniqueNames(entries)
+	want := []string{"a.bin", "a_2.bin", "a_1.bin", "00000003", "00000004", "00000005", "00000006", "00000007", "00000008", "a_3.bin"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
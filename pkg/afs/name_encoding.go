@@ -0,0 +1,189 @@
+package afs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+type NameEncoding int
+
+const (
+	NameEncodingASCII NameEncoding = iota
+	NameEncodingShiftJIS
+	NameEncodingUTF8
+)
+
+func (self NameEncoding) String() string {
+	switch self {
+	case NameEncodingASCII:
+		return "ascii"
+	case NameEncodingShiftJIS:
+		return "shift-jis"
+	case NameEncodingUTF8:
+		return "utf-8"
+	default:
+		return fmt.Sprintf("name_encoding(%d)", int(self))
+	}
+}
+
+func ParseNameEncoding(value string) (NameEncoding, error) {
+	switch strings.ToLower(value) {
+	case "ascii":
+		return NameEncodingASCII, nil
+	case "shift-jis", "shiftjis", "sjis":
+		return NameEncodingShiftJIS, nil
+	case "utf-8", "utf8":
+		return NameEncodingUTF8, nil
+	default:
+		return NameEncodingASCII, fmt.Errorf("%w %q, use ascii, shift-jis or utf-8", ErrUnknownNameEncoding, value)
+	}
+}
+
+func (self NameEncoding) MarshalText() ([]byte, error) {
+	return []byte(self.String()), nil
+}
+
+func (self *NameEncoding) UnmarshalText(text []byte) error {
+	encoding, err := ParseNameEncoding(string(text))
+	if err != nil {
+		return err
+	}
+
+	*self = encoding
+
+	return nil
+}
+
+// Decode turns a raw name field into a name, stopping at the first NUL.
+// Bytes the encoding cannot represent become '_', the raw bytes are kept in
+// Entry.RawName so the original field can still be written back.
+func (self NameEncoding) Decode(raw []byte) string {
+	if end := bytes.IndexByte(raw, 0); end >= 0 {
+		raw = raw[:end]
+	}
+
+	var name string
+	switch self {
+	case NameEncodingShiftJIS:
+		decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(raw)
+		if err != nil {
+			return NameEncodingASCII.Decode(raw)
+		}
+		name = string(decoded)
+	case NameEncodingUTF8:
+		name = string(raw)
+	default:
+		name = strings.Map(func(r rune) rune {
+			if r >= utf8.RuneSelf {
+				return '_'
+			}
+			return r
+		}, string(raw))
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r == utf8.RuneError {
+			return '_'
+		}
+		if !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, name)
+}
+
+func (self NameEncoding) Encode(name string) ([]byte, error) {
+	var raw []byte
+	switch self {
+	case NameEncodingShiftJIS:
+		encoded, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(name))
+		if err != nil {
+			return nil, fmt.Errorf("%w, %s cannot be written as %s", ErrEntryNameEncoding, name, self)
+		}
+		raw = encoded
+	case NameEncodingUTF8:
+		if !utf8.ValidString(name) {
+			return nil, fmt.Errorf("%w, %s is not valid %s", ErrEntryNameEncoding, name, self)
+		}
+		raw = []byte(name)
+	default:
+		for _, r := range name {
+			if r >= utf8.RuneSelf {
+				return nil, fmt.Errorf("%w, %s cannot be written as %s", ErrEntryNameEncoding, name, self)
+			}
+		}
+		raw = []byte(name)
+	}
+
+	if uint32(len(raw)) > MaxEntryNameLength {
+		return nil, fmt.Errorf("%w, %s is %d bytes as %s, the limit is %d", ErrEntryNameTooLong, name, len(raw), self, MaxEntryNameLength)
+	}
+
+	return raw, nil
+}
+
+// nameField returns the bytes written for an entry name. The raw field read
+// from the archive is reused while the name still decodes from it.
+func (self NameEncoding) nameField(entry *Entry) ([]byte, error) {
+	if entry.RawName != nil && uint32(len(entry.RawName)) <= MaxEntryNameLength && self.Decode(entry.RawName) == entry.Name {
+		return entry.RawName, nil
+	}
+
+	return self.Encode(entry.Name)
+}
+
+// DetectNameEncoding picks ASCII when every name is plain ASCII, then UTF-8
+// when every name is valid UTF-8, then Shift-JIS when every name decodes.
+func DetectNameEncoding(raws [][]byte) NameEncoding {
+	ascii := true
+	valid := true
+	shiftJIS := true
+
+	for _, raw := range raws {
+		if end := bytes.IndexByte(raw, 0); end >= 0 {
+			raw = raw[:end]
+		}
+
+		for _, b := range raw {
+			if b >= utf8.RuneSelf {
+				ascii = false
+				break
+			}
+		}
+
+		if !utf8.Valid(raw) {
+			valid = false
+		}
+
+		if decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(raw); err != nil || bytes.ContainsRune(decoded, utf8.RuneError) {
+			shiftJIS = false
+		}
+	}
+
+	switch {
+	case ascii:
+		return NameEncodingASCII
+	case valid:
+		return NameEncodingUTF8
+	case shiftJIS:
+		return NameEncodingShiftJIS
+	default:
+		return NameEncodingASCII
+	}
+}
+
+// SetNameEncoding decodes every entry name again from its raw field.
+func (self *Afs) SetNameEncoding(encoding NameEncoding) {
+	self.NameEncoding = encoding
+
+	for _, entry := range self.Entries {
+		if entry.RawName != nil {
+			entry.Name = encoding.Decode(entry.RawName)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package afs
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+type OverlayFile struct {
+	Name          string
+	Path          string
+	LastWriteTime Timestamp
+	Source        EntrySource
+}
+
+type OverlayMatch struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+}
+
+type OverlayReport struct {
+	Matched   []*OverlayMatch `json:"matched"`
+	Unmatched []string        `json:"unmatched"`
+}
+
+// Overlay replaces entries with the files in dir, matched by entry name, or
+// by index when the archive has no names. Entries that are not replaced keep
+// reading from their original source, so Pack streams them from the base.
+func (self *Afs) Overlay(dir string) (*OverlayReport, error) {
+	files, err := ReadOverlayDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return self.OverlayFiles(files)
+}
+
+func ReadOverlayDir(dir string) ([]*OverlayFile, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*OverlayFile{}
+	for _, dirEntry := range dirEntries {
+		path := filepath.Join(dir, dirEntry.Name())
+
+		file := &OverlayFile{
+			Name: dirEntry.Name(),
+			Path: path,
+		}
+		files = append(files, file)
+
+		if dirEntry.IsDir() {
+			continue
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		if file.Source, err = NewFileSource(path); err != nil {
+			return nil, err
+		}
+		file.LastWriteTime = NewTimestamp(info.ModTime())
+	}
+
+	return files, nil
+}
+
+func (self *Afs) OverlayFiles(files []*OverlayFile) (*OverlayReport, error) {
+	report := &OverlayReport{
+		Matched:   []*OverlayMatch{},
+		Unmatched: []string{},
+	}
+
+	indices := _NameIndices(UniqueNames(self.Entries))
+
+	for _, file := range files {
+		index := self.matchEntry(indices, file.Name)
+		if index < 0 || file.Source == nil {
+			report.Unmatched = append(report.Unmatched, file.Path)
+			continue
+		}
+
+		size := file.Source.Size()
+		if size < 0 || size > math.MaxUint32 {
+			return nil, _EntryError(index, self.Entries[index], fmt.Errorf("%w, %s has %d bytes", ErrEntrySizeOutOfRange, file.Path, size))
+		}
+
+		// Custom data that is not the size, such as a CRC or type ID, is kept.
+		entry := self.Entries[index]
+		if entry.IsNull || entry.CustomData == entry.Size {
+			entry.CustomData = uint32(size)
+		}
+		entry.Data = file.Source
+		entry.reader = nil
+		entry.Source = file.Path
+		entry.Offset = 0
+		entry.Size = uint32(size)
+		entry.IsNull = false
+		if !file.LastWriteTime.IsZero() {
+			entry.LastWriteTime = file.LastWriteTime
+		}
+
+		report.Matched = append(
+			report.Matched,
+			&OverlayMatch{
+				Index: index,
+				Name:  entry.Name,
+				Path:  file.Path,
+			},
+		)
+	}
+
+	return report, nil
+}
+
+// MatchEntry returns the index of the entry a file name refers to, or -1.
+// Names are matched as unpacked, with duplicates numbered name_N.ext, and
+// archives without names also match a plain index such as 12 or 12.adx.
+func (self *Afs) MatchEntry(name string) int {
+	return self.matchEntry(_NameIndices(UniqueNames(self.Entries)), name)
+}
+
+func (self *Afs) matchEntry(indices map[string]int, name string) int {
+	if index, found := indices[name]; found {
+		return index
+	}
+
+	if self.AttributesInfo == AttributesInfoNoAttribute {
+		if index, err := strconv.Atoi(_BasenameWithoutExtension(name)); err == nil && index >= 0 && index < len(self.Entries) {
+			return index
+		}
+	}
+
+	return -1
+}
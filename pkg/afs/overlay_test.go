@@ -0,0 +1,86 @@
+package afs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	base := New()
+	for i, name := range []string{"a.bin", "a.bin", "crc.bin"} {
+		if err := base.AddEntryFromSource(NewBytesSource(_TestData(i, 700)), name, _TestTimestamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base.AddNullEntry("")
+	base.Entries[2].CustomData = 0xDEADBEEF
+
+	basePath := filepath.Join(t.TempDir(), "base.afs")
+	if err := base.Pack(context.Background(), basePath, _TestProgress, _TestProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"a_1.bin":     _TestData(10, 3000),
+		"crc.bin":     _TestData(11, 10),
+		"unknown.bin": _TestData(12, 10),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := _OpenTestArchive(t, basePath)
+	report, err := a.Overlay(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matched := []int{}
+	for _, match := range report.Matched {
+		matched = append(matched, match.Index)
+	}
+	slices.Sort(matched)
+
+	if !slices.Equal(matched, []int{1, 2}) {
+		t.Errorf("matched entries %v, want [1 2]", matched)
+	}
+	if len(report.Unmatched) != 1 || filepath.Base(report.Unmatched[0]) != "unknown.bin" {
+		t.Errorf("unmatched files %v, want only unknown.bin", report.Unmatched)
+	}
+
+	output := filepath.Join(t.TempDir(), "overlay.afs")
+	if err := a.Pack(context.Background(), output, _TestProgress, _TestProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	overlaid := _OpenTestArchive(t, output)
+	_CheckTestArchive(t, overlaid, []_TestEntry{
+		{name: "a.bin", data: _TestData(0, 700)},
+		{name: "a.bin", data: files["a_1.bin"]},
+		{name: "crc.bin", data: files["crc.bin"]},
+		{null: true},
+	})
+
+	if custom := overlaid.Entries[1].CustomData; custom != 3000 {
+		t.Errorf("custom data is %d, want the new size 3000", custom)
+	}
+	if custom := overlaid.Entries[2].CustomData; custom != 0xDEADBEEF {
+		t.Errorf("custom data is 0x%X, want 0xDEADBEEF kept", custom)
+	}
+}
+
+func TestMatchEntryWithoutNames(t *testing.T) {
+	a := _OpenTestArchive(t, _BuildTestArchive(t, func(a *Afs) { a.AttributesInfo = AttributesInfoNoAttribute }, _TestEntries()))
+
+	for name, want := range map[string]int{"00000003": 3, "3.adx": 3, "4": 4, "5": -1, "first.bin": -1} {
+		if got := a.MatchEntry(name); got != want {
+			t.Errorf("%s matched entry %d, want %d", name, got, want)
+		}
+	}
+}
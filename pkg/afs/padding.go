@@ -0,0 +1,153 @@
+package afs
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const _PaddingSamples = 64
+
+// detectPadding guesses DataAlignment, EndAlignment and PaddingFill from the
+// entry offsets, the file size and the bytes following entry data. Values
+// that cannot be told apart from the defaults keep the defaults.
+func (self *Afs) detectPadding() error {
+	self.DataAlignment = AlignmentSize
+	self.EndAlignment = AlignmentSize
+	self.PaddingFill = 0
+
+	entries := []*Entry{}
+	for _, entry := range self.Entries {
+		if !entry.IsNull {
+			entries = append(entries, entry)
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	slices.SortStableFunc(entries, func(a, b *Entry) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+
+	alignment := MaxDetectedAlignment
+	for _, entry := range entries {
+		alignment = min(alignment, _LargestPowerOfTwo(entry.Offset))
+	}
+
+	if alignment < AlignmentSize || !_IsContiguous(entries, AlignmentSize) {
+		self.DataAlignment = alignment
+	}
+
+	end := uint64(0)
+	for _, entry := range entries {
+		end = max(end, uint64(entry.Offset)+uint64(entry.Size))
+	}
+
+	if self.AttributesInfo != AttributesInfoNoAttribute {
+		end = max(end, uint64(self.attributesOffset)+uint64(self.attributesSize))
+	}
+
+	if end <= uint64(self.size) && self.size <= int64(^uint32(0)) {
+		size := uint32(self.size)
+		switch {
+		case _Pad(uint32(end), self.DataAlignment) == size:
+			self.EndAlignment = self.DataAlignment
+		case uint32(end) == size:
+			self.EndAlignment = 1
+		case _Pad(uint32(end), min(_LargestPowerOfTwo(size), MaxDetectedAlignment)) == size:
+			self.EndAlignment = min(_LargestPowerOfTwo(size), MaxDetectedAlignment)
+		default:
+			self.EndAlignment = self.DataAlignment
+		}
+	} else {
+		self.EndAlignment = self.DataAlignment
+	}
+
+	histogram := [256]int{}
+	samples := 0
+	for i, entry := range entries {
+		position := uint64(entry.Offset) + uint64(entry.Size)
+
+		limit := uint64(self.size)
+		if i+1 < len(entries) {
+			limit = min(limit, uint64(entries[i+1].Offset))
+		}
+
+		if self.AttributesInfo != AttributesInfoNoAttribute && uint64(self.attributesOffset) >= position {
+			limit = min(limit, uint64(self.attributesOffset))
+		}
+
+		if position >= limit {
+			continue
+		}
+
+		b := []byte{0}
+		if _, err := self.reader.ReadAt(b, int64(position)); err != nil {
+			return _ReadError("padding", err)
+		}
+
+		histogram[b[0]] += 1
+		samples += 1
+		if samples == _PaddingSamples {
+			break
+		}
+	}
+
+	for b := range histogram {
+		if histogram[b] > histogram[self.PaddingFill] {
+			self.PaddingFill = byte(b)
+		}
+	}
+
+	return nil
+}
+
+func _IsContiguous(entries []*Entry, alignment uint32) bool {
+	for i := 1; i < len(entries); i++ {
+		end := uint64(entries[i-1].Offset) + uint64(entries[i-1].Size)
+		if end > uint64(^uint32(0)) || entries[i].Offset != _Pad(uint32(end), alignment) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func _LargestPowerOfTwo(value uint32) uint32 {
+	if value == 0 {
+		return MaxDetectedAlignment
+	}
+
+	return value & -value
+}
+
+func _ValidateAlignment(dataAlignment uint32, endAlignment uint32) error {
+	if dataAlignment == 0 {
+		return fmt.Errorf("%w, data alignment is 0", ErrInvalidAlignment)
+	}
+
+	if endAlignment == 0 {
+		return fmt.Errorf("%w, end alignment is 0", ErrInvalidAlignment)
+	}
+
+	return nil
+}
+
+func _WriteFill(w io.WriterAt, intervals []*_Interval, fill byte) error {
+	buf := bytes.Repeat([]byte{fill}, int(_LayoutChunkSize))
+
+	for _, interval := range intervals {
+		for offset := interval.start; offset < interval.end; offset += _LayoutChunkSize {
+			size := min(interval.end-offset, _LayoutChunkSize)
+			if _, err := w.WriteAt(buf[:size], int64(offset)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,469 @@
+package afs
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+
+	"github.com/anasrar/afs/internal/bps"
+)
+
+type PatchFormat int
+
+const (
+	PatchFormatAfs PatchFormat = iota
+	PatchFormatBps
+)
+
+const PatchVersion uint8 = 1
+
+var PatchSignature = []byte("AFSPATCH")
+
+const (
+	_PatchOpEnd byte = iota
+	_PatchOpCopy
+	_PatchOpLiteral
+	_PatchOpFill
+)
+
+const _PatchChunkSize uint64 = 0x10000
+const _PatchMinFillRun = 0x20
+
+type PatchOutput interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+type _PatchOp struct {
+	kind   byte
+	offset uint64
+	length uint64
+	fill   byte
+}
+
+func CreatePatch(w io.Writer, base *Afs, target *Afs, format PatchFormat) error {
+	if base.reader == nil || target.reader == nil {
+		return ErrNotArchiveBacked
+	}
+
+	ops, err := _PlanPatch(base, target)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case PatchFormatAfs:
+		return _WriteAfsPatch(w, base, target, ops)
+	case PatchFormatBps:
+		return _WriteBpsPatch(w, base, target, ops)
+	default:
+		return fmt.Errorf("Unknown patch format %d", format)
+	}
+}
+
+// ApplyPatch detects the patch format, checks that base is the archive the
+// patch was created from and writes the patched archive to output.
+func ApplyPatch(output PatchOutput, base io.ReaderAt, baseSize int64, patch io.Reader) error {
+	reader := bufio.NewReader(patch)
+
+	magic, err := reader.Peek(len(PatchSignature))
+	if err != nil {
+		return _ReadError("patch header", err)
+	}
+
+	if bytes.HasPrefix(magic, bps.Magic) {
+		buf, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+
+		err = bps.Apply(output, base, baseSize, buf)
+		switch {
+		case errors.Is(err, bps.ErrSourceMismatch):
+			return fmt.Errorf("%w: %w", ErrPatchBaseMismatch, err)
+		case errors.Is(err, bps.ErrTargetMismatch):
+			return fmt.Errorf("%w: %w", ErrPatchTargetMismatch, err)
+		case errors.Is(err, bps.ErrInvalidPatch):
+			return fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+		}
+		return err
+	}
+
+	if !bytes.Equal(magic, PatchSignature) {
+		return ErrInvalidPatch
+	}
+
+	if _, err := reader.Discard(len(PatchSignature)); err != nil {
+		return err
+	}
+
+	var header struct {
+		Version        uint8
+		BaseSize       uint64
+		BaseChecksum   [sha256.Size]byte
+		TargetSize     uint64
+		TargetChecksum [sha256.Size]byte
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return _ReadError("patch header", err)
+	}
+
+	if header.Version != PatchVersion {
+		return fmt.Errorf("%w, unsupported version %d", ErrInvalidPatch, header.Version)
+	}
+
+	if uint64(baseSize) != header.BaseSize {
+		return fmt.Errorf("%w, expected %d bytes, got %d", ErrPatchBaseMismatch, header.BaseSize, baseSize)
+	}
+
+	baseChecksum, err := _Checksum(base, baseSize)
+	if err != nil {
+		return err
+	}
+
+	if baseChecksum != header.BaseChecksum {
+		return fmt.Errorf("%w, checksum mismatch", ErrPatchBaseMismatch)
+	}
+
+	hash := sha256.New()
+	writer := io.MultiWriter(io.NewOffsetWriter(output, 0), hash)
+	written := uint64(0)
+
+	for {
+		kind, err := reader.ReadByte()
+		if err != nil {
+			return _ReadError("patch", err)
+		}
+
+		if kind == _PatchOpEnd {
+			break
+		}
+
+		var value uint64
+		var length uint64
+
+		if kind == _PatchOpCopy || kind == _PatchOpFill {
+			if value, err = binary.ReadUvarint(reader); err != nil {
+				return _ReadError("patch", err)
+			}
+		}
+
+		if length, err = binary.ReadUvarint(reader); err != nil {
+			return _ReadError("patch", err)
+		}
+
+		if written+length > header.TargetSize {
+			return fmt.Errorf("%w, output exceeds target size", ErrInvalidPatch)
+		}
+
+		switch kind {
+		case _PatchOpCopy:
+			if value+length > uint64(baseSize) {
+				return fmt.Errorf("%w, copy past end of base", ErrInvalidPatch)
+			}
+			_, err = io.Copy(writer, io.NewSectionReader(base, int64(value), int64(length)))
+		case _PatchOpLiteral:
+			_, err = io.CopyN(writer, reader, int64(length))
+		case _PatchOpFill:
+			_, err = io.CopyN(writer, _FillReader(byte(value)), int64(length))
+		default:
+			return fmt.Errorf("%w, unknown operation %d", ErrInvalidPatch, kind)
+		}
+
+		if err != nil {
+			return _ReadError("patch", err)
+		}
+
+		written += length
+	}
+
+	if written != header.TargetSize || !bytes.Equal(hash.Sum(nil), header.TargetChecksum[:]) {
+		return ErrPatchTargetMismatch
+	}
+
+	return nil
+}
+
+// _PlanPatch describes target as a sequence of copies from base for entries
+// whose data exists in base, and literal or fill runs for everything else.
+func _PlanPatch(base *Afs, target *Afs) ([]*_PatchOp, error) {
+	baseBySize := map[uint32][]*Entry{}
+	for _, entry := range base.Entries {
+		if !entry.IsNull && uint64(entry.Offset)+uint64(entry.Size) <= uint64(base.size) {
+			baseBySize[entry.Size] = append(baseBySize[entry.Size], entry)
+		}
+	}
+
+	hashes := map[*Entry][sha256.Size]byte{}
+	hashOf := func(afs *Afs, entry *Entry) ([sha256.Size]byte, error) {
+		if sum, found := hashes[entry]; found {
+			return sum, nil
+		}
+
+		sum, err := _Checksum(io.NewSectionReader(afs.reader, int64(entry.Offset), int64(entry.Size)), int64(entry.Size))
+		if err != nil {
+			return sum, err
+		}
+
+		hashes[entry] = sum
+		return sum, nil
+	}
+
+	entries := []*Entry{}
+	for _, entry := range target.Entries {
+		if !entry.IsNull && entry.Size > 0 && uint64(entry.Offset)+uint64(entry.Size) <= uint64(target.size) {
+			entries = append(entries, entry)
+		}
+	}
+
+	slices.SortStableFunc(entries, func(a, b *Entry) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+
+	ops := []*_PatchOp{}
+	position := uint64(0)
+
+	for _, entry := range entries {
+		start := uint64(entry.Offset)
+		end := start + uint64(entry.Size)
+		if end <= position {
+			continue
+		}
+
+		if start < position {
+			gap, err := _PlanGap(target.reader, position, end)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, gap...)
+			position = end
+			continue
+		}
+
+		gap, err := _PlanGap(target.reader, position, start)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, gap...)
+
+		var source *Entry
+		if candidates := baseBySize[entry.Size]; len(candidates) > 0 {
+			sum, err := hashOf(target, entry)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, candidate := range candidates {
+				candidateSum, err := hashOf(base, candidate)
+				if err != nil {
+					return nil, err
+				}
+
+				if candidateSum == sum {
+					source = candidate
+					break
+				}
+			}
+		}
+
+		if source != nil {
+			ops = append(ops, &_PatchOp{kind: _PatchOpCopy, offset: uint64(source.Offset), length: uint64(entry.Size)})
+		} else {
+			ops = append(ops, &_PatchOp{kind: _PatchOpLiteral, offset: start, length: uint64(entry.Size)})
+		}
+
+		position = end
+	}
+
+	gap, err := _PlanGap(target.reader, position, uint64(target.size))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ops, gap...), nil
+}
+
+func _PlanGap(reader io.ReaderAt, start uint64, end uint64) ([]*_PatchOp, error) {
+	ops := []*_PatchOp{}
+	buf := make([]byte, _PatchChunkSize)
+
+	add := func(op *_PatchOp) {
+		if len(ops) > 0 {
+			last := ops[len(ops)-1]
+			if last.kind == op.kind && (op.kind != _PatchOpFill || last.fill == op.fill) && (op.kind != _PatchOpLiteral || last.offset+last.length == op.offset) {
+				last.length += op.length
+				return
+			}
+		}
+		ops = append(ops, op)
+	}
+
+	for offset := start; offset < end; offset += _PatchChunkSize {
+		chunk := buf[:min(end-offset, _PatchChunkSize)]
+		if _, err := reader.ReadAt(chunk, int64(offset)); err != nil {
+			return nil, _ReadError("patch target", err)
+		}
+
+		literal := 0
+		for i := 0; i < len(chunk); {
+			run := 1
+			for i+run < len(chunk) && chunk[i+run] == chunk[i] {
+				run += 1
+			}
+
+			if run >= _PatchMinFillRun {
+				if literal < i {
+					add(&_PatchOp{kind: _PatchOpLiteral, offset: offset + uint64(literal), length: uint64(i - literal)})
+				}
+				add(&_PatchOp{kind: _PatchOpFill, fill: chunk[i], length: uint64(run)})
+				literal = i + run
+			}
+
+			i += run
+		}
+
+		if literal < len(chunk) {
+			add(&_PatchOp{kind: _PatchOpLiteral, offset: offset + uint64(literal), length: uint64(len(chunk) - literal)})
+		}
+	}
+
+	return ops, nil
+}
+
+func _WriteAfsPatch(w io.Writer, base *Afs, target *Afs, ops []*_PatchOp) error {
+	baseChecksum, err := _Checksum(base.reader, base.size)
+	if err != nil {
+		return err
+	}
+
+	targetChecksum, err := _Checksum(target.reader, target.size)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(w)
+
+	if _, err := writer.Write(PatchSignature); err != nil {
+		return err
+	}
+
+	for _, v := range []any{
+		PatchVersion,
+		uint64(base.size),
+		baseChecksum,
+		uint64(target.size),
+		targetChecksum,
+	} {
+		if err := binary.Write(writer, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	for _, op := range ops {
+		if err := writer.WriteByte(op.kind); err != nil {
+			return err
+		}
+
+		switch op.kind {
+		case _PatchOpCopy:
+			if _, err := writer.Write(binary.AppendUvarint(nil, op.offset)); err != nil {
+				return err
+			}
+		case _PatchOpFill:
+			if _, err := writer.Write(binary.AppendUvarint(nil, uint64(op.fill))); err != nil {
+				return err
+			}
+		}
+
+		if _, err := writer.Write(binary.AppendUvarint(nil, op.length)); err != nil {
+			return err
+		}
+
+		if op.kind == _PatchOpLiteral {
+			if _, err := io.Copy(writer, io.NewSectionReader(target.reader, int64(op.offset), int64(op.length))); err != nil {
+				return _ReadError("patch target", err)
+			}
+		}
+	}
+
+	if err := writer.WriteByte(_PatchOpEnd); err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+func _WriteBpsPatch(w io.Writer, base *Afs, target *Afs, ops []*_PatchOp) error {
+	baseCrc := crc32.NewIEEE()
+	if _, err := io.Copy(baseCrc, io.NewSectionReader(base.reader, 0, base.size)); err != nil {
+		return _ReadError("patch base", err)
+	}
+
+	targetCrc := crc32.NewIEEE()
+	if _, err := io.Copy(targetCrc, io.NewSectionReader(target.reader, 0, target.size)); err != nil {
+		return _ReadError("patch target", err)
+	}
+
+	writer := bufio.NewWriter(w)
+	encoder := bps.NewEncoder(writer, uint64(base.size), uint64(target.size))
+	buf := make([]byte, _PatchChunkSize)
+
+	for _, op := range ops {
+		switch op.kind {
+		case _PatchOpCopy:
+			if err := encoder.Copy(op.offset, op.length); err != nil {
+				return err
+			}
+		case _PatchOpFill:
+			if err := encoder.Fill(op.fill, op.length); err != nil {
+				return err
+			}
+		case _PatchOpLiteral:
+			for offset := op.offset; offset < op.offset+op.length; offset += _PatchChunkSize {
+				chunk := buf[:min(op.offset+op.length-offset, _PatchChunkSize)]
+				if _, err := target.reader.ReadAt(chunk, int64(offset)); err != nil {
+					return _ReadError("patch target", err)
+				}
+
+				if err := encoder.Literal(chunk); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := encoder.Close(baseCrc.Sum32(), targetCrc.Sum32()); err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+func _Checksum(reader io.ReaderAt, size int64) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(reader, 0, size)); err != nil {
+		return sum, _ReadError("checksum", err)
+	}
+
+	copy(sum[:], hash.Sum(nil))
+	return sum, nil
+}
+
+type _FillReader byte
+
+func (self _FillReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(self)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,106 @@
+package afs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// _BuildPatchTestArchives returns a base archive and a target made from it by
+// replacing, appending and removing entries.
+func _BuildPatchTestArchives(t *testing.T) (string, string) {
+	t.Helper()
+
+	basePath := _BuildTestArchive(t, nil, _TestEntries())
+	targetPath := filepath.Join(t.TempDir(), "target.afs")
+	if err := os.WriteFile(targetPath, _ReadTestFile(t, basePath), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := _OpenTestArchiveForUpdate(t, targetPath)
+	if err := target.ReplaceEntry(0, NewBytesSource(_TestData(20, 2500))); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.AppendEntry(NewBytesSource(bytes.Repeat([]byte{0xAB}, 0x3000)), "fill.bin", _TestTimestamp); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.RemoveEntry(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return basePath, targetPath
+}
+
+// _ApplyTestPatch applies patch to the archive at basePath and returns the
+// patched bytes.
+func _ApplyTestPatch(t *testing.T, basePath string, patch []byte) ([]byte, error) {
+	t.Helper()
+
+	base, err := os.Open(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer base.Close()
+
+	info, err := base.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := os.Create(filepath.Join(t.TempDir(), "patched.afs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+
+	if err := ApplyPatch(output, base, info.Size(), bytes.NewReader(patch)); err != nil {
+		return nil, err
+	}
+
+	return _ReadTestFile(t, output.Name()), nil
+}
+
+func TestPatchApply(t *testing.T) {
+	basePath, targetPath := _BuildPatchTestArchives(t)
+	want := _ReadTestFile(t, targetPath)
+
+	for _, format := range []PatchFormat{PatchFormatAfs, PatchFormatBps} {
+		patch := bytes.Buffer{}
+		if err := CreatePatch(&patch, _OpenTestArchive(t, basePath), _OpenTestArchive(t, targetPath), format); err != nil {
+			t.Fatal(err)
+		}
+
+		if patch.Len() >= len(want) {
+			t.Errorf("format %d: patch has %d bytes, not smaller than the %d byte target", format, patch.Len(), len(want))
+		}
+
+		got, err := _ApplyTestPatch(t, basePath, patch.Bytes())
+		if err != nil {
+			t.Fatalf("format %d: %s", format, err)
+		}
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("format %d: patched archive differs from the target", format)
+		}
+	}
+}
+
+func TestPatchApplyWrongBase(t *testing.T) {
+	basePath, targetPath := _BuildPatchTestArchives(t)
+
+	for _, format := range []PatchFormat{PatchFormatAfs, PatchFormatBps} {
+		patch := bytes.Buffer{}
+		if err := CreatePatch(&patch, _OpenTestArchive(t, basePath), _OpenTestArchive(t, targetPath), format); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := _ApplyTestPatch(t, targetPath, patch.Bytes()); !errors.Is(err, ErrPatchBaseMismatch) {
+			t.Errorf("format %d: got %v, want %v", format, err, ErrPatchBaseMismatch)
+		}
+	}
+}
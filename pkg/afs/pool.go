@@ -0,0 +1,92 @@
+package afs
+
+import (
+	"context"
+	"sync"
+)
+
+func _RunEntries(
+	ctx context.Context,
+	workers int,
+	entries []*Entry,
+	total uint32,
+	skip func(i int, entry *Entry) bool,
+	onStart,
+	onDone func(total uint32, current uint32, name string),
+	process func(i int, entry *Entry) error,
+) error {
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+
+	indices := make(chan int)
+
+	for w := 0; w < max(workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indices {
+				if workerCtx.Err() != nil {
+					continue
+				}
+
+				entry := entries[i]
+
+				mutex.Lock()
+				onStart(total, uint32(i+1), entry.Name)
+				mutex.Unlock()
+
+				if err := process(i, entry); err != nil {
+					mutex.Lock()
+					if firstErr == nil {
+						firstErr = _EntryError(i, entry, err)
+					}
+					mutex.Unlock()
+
+					cancel()
+					continue
+				}
+
+				mutex.Lock()
+				onDone(total, uint32(i+1), entry.Name)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for i, entry := range entries {
+		if skip != nil && skip(i, entry) {
+			continue
+		}
+
+		if workerCtx.Err() != nil {
+			break
+		}
+
+		select {
+		case <-workerCtx.Done():
+		case indices <- i:
+		}
+	}
+
+	close(indices)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	select {
+	case <-ctx.Done():
+		return _CanceledError(ctx.Err())
+	default:
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package afs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+type EntrySource interface {
+	Size() int64
+	Open() (io.ReadCloser, error)
+}
+
+type _SectionSource struct {
+	reader io.ReaderAt
+	offset int64
+	size   int64
+}
+
+func (self *_SectionSource) Size() int64 {
+	return self.size
+}
+
+func (self *_SectionSource) Open() (io.ReadCloser, error) {
+	return &_EntryReader{
+		SectionReader: io.NewSectionReader(self.reader, self.offset, self.size),
+	}, nil
+}
+
+func NewSectionSource(reader io.ReaderAt, offset int64, size int64) EntrySource {
+	return &_SectionSource{
+		reader: reader,
+		offset: offset,
+		size:   size,
+	}
+}
+
+func NewBytesSource(b []byte) EntrySource {
+	return NewSectionSource(bytes.NewReader(b), 0, int64(len(b)))
+}
+
+type _FileSource struct {
+	path string
+	size int64
+}
+
+func (self *_FileSource) Size() int64 {
+	return self.size
+}
+
+func (self *_FileSource) Open() (io.ReadCloser, error) {
+	file, err := os.Open(self.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &_EntryReader{
+		SectionReader: io.NewSectionReader(file, 0, self.size),
+		closer:        file,
+	}, nil
+}
+
+func NewFileSource(path string) (EntrySource, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	return &_FileSource{
+		path: path,
+		size: info.Size(),
+	}, nil
+}
+
+type _FuncSource struct {
+	size int64
+	open func() (io.ReadCloser, error)
+}
+
+func (self *_FuncSource) Size() int64 {
+	return self.size
+}
+
+func (self *_FuncSource) Open() (io.ReadCloser, error) {
+	return self.open()
+}
+
+func NewFuncSource(size int64, open func() (io.ReadCloser, error)) EntrySource {
+	return &_FuncSource{
+		size: size,
+		open: open,
+	}
+}
+
+type _EntrySource struct {
+	entry *Entry
+}
+
+func (self *_EntrySource) Size() int64 {
+	return int64(self.entry.Size)
+}
+
+func (self *_EntrySource) Open() (io.ReadCloser, error) {
+	return self.entry.Open()
+}
+
+func NewEntrySource(entry *Entry) EntrySource {
+	return &_EntrySource{
+		entry: entry,
+	}
+}
@@ -0,0 +1,149 @@
+package afs
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+type Layer struct {
+	Name  string
+	Files []*OverlayFile
+}
+
+func NewDirLayer(name string, dir string) (*Layer, error) {
+	files, err := ReadOverlayDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Layer{
+		Name:  name,
+		Files: files,
+	}, nil
+}
+
+// NewArchiveLayer uses the entries of a mod archive as overlay files. Entries
+// identical to the matching base entry are left out, so a mod shipped as a
+// full copy of the base only claims what it changes. The archive must stay
+// open until the stacked archive has been packed.
+func NewArchiveLayer(name string, archive *Afs, base *Afs) (*Layer, error) {
+	layer := &Layer{
+		Name:  name,
+		Files: []*OverlayFile{},
+	}
+
+	baseIndices := _NameIndices(UniqueNames(base.Entries))
+
+	for i, unique := range UniqueNames(archive.Entries) {
+		entry := archive.Entries[i]
+		if entry.IsNull {
+			continue
+		}
+
+		if index := base.matchEntry(baseIndices, unique); index >= 0 {
+			baseEntry := base.Entries[index]
+			if !baseEntry.IsNull && baseEntry.Size == entry.Size {
+				equal, err := _EqualContent(baseEntry, entry)
+				if err != nil {
+					return nil, _EntryError(i, entry, err)
+				}
+
+				if equal {
+					continue
+				}
+			}
+		}
+
+		layer.Files = append(
+			layer.Files,
+			&OverlayFile{
+				Name:          unique,
+				Path:          fmt.Sprintf("%s:%s", name, unique),
+				LastWriteTime: entry.LastWriteTime,
+				Source:        NewEntrySource(entry),
+			},
+		)
+	}
+
+	return layer, nil
+}
+
+type StackEntry struct {
+	Index  int      `json:"index"`
+	Name   string   `json:"name"`
+	Winner string   `json:"winner"`
+	Path   string   `json:"path"`
+	Layers []string `json:"layers"`
+}
+
+type StackUnmatched struct {
+	Layer string `json:"layer"`
+	Path  string `json:"path"`
+}
+
+type StackReport struct {
+	Entries   []*StackEntry     `json:"entries"`
+	Unmatched []*StackUnmatched `json:"unmatched"`
+}
+
+func (self *StackReport) Conflicts() []*StackEntry {
+	conflicts := []*StackEntry{}
+	for _, entry := range self.Entries {
+		if len(entry.Layers) > 1 {
+			conflicts = append(conflicts, entry)
+		}
+	}
+	return conflicts
+}
+
+// Stack overlays each layer in order, so a later layer wins over an earlier
+// one for the same entry. The report lists every layer that touched an entry.
+func (self *Afs) Stack(layers []*Layer) (*StackReport, error) {
+	report := &StackReport{
+		Entries:   []*StackEntry{},
+		Unmatched: []*StackUnmatched{},
+	}
+
+	touched := map[int]*StackEntry{}
+
+	for _, layer := range layers {
+		overlay, err := self.OverlayFiles(layer.Files)
+		if err != nil {
+			return nil, fmt.Errorf("Layer %s: %w", layer.Name, err)
+		}
+
+		for _, match := range overlay.Matched {
+			entry, found := touched[match.Index]
+			if !found {
+				entry = &StackEntry{
+					Index:  match.Index,
+					Name:   match.Name,
+					Layers: []string{},
+				}
+				touched[match.Index] = entry
+				report.Entries = append(report.Entries, entry)
+			}
+
+			entry.Winner = layer.Name
+			entry.Path = match.Path
+			entry.Layers = append(entry.Layers, layer.Name)
+		}
+
+		for _, path := range overlay.Unmatched {
+			report.Unmatched = append(
+				report.Unmatched,
+				&StackUnmatched{
+					Layer: layer.Name,
+					Path:  path,
+				},
+			)
+		}
+	}
+
+	slices.SortFunc(report.Entries, func(a, b *StackEntry) int {
+		return cmp.Compare(a.Index, b.Index)
+	})
+
+	return report, nil
+}
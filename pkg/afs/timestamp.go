@@ -0,0 +1,121 @@
+package afs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp holds the six raw fields of an attribute date. Fields are kept
+// as read, so zero or out of range dates round trip unchanged.
+type Timestamp struct {
+	Year   uint16
+	Month  uint16
+	Day    uint16
+	Hour   uint16
+	Minute uint16
+	Second uint16
+}
+
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{
+		Year:   uint16(t.Year()),
+		Month:  uint16(t.Month()),
+		Day:    uint16(t.Day()),
+		Hour:   uint16(t.Hour()),
+		Minute: uint16(t.Minute()),
+		Second: uint16(t.Second()),
+	}
+}
+
+// ParseTimestamp reads the "2006-01-02 15:04:05" form written by String.
+// Each field only has to fit in a uint16, so invalid dates are accepted.
+func ParseTimestamp(value string) (Timestamp, error) {
+	date, clock, found := strings.Cut(value, " ")
+	if !found {
+		return Timestamp{}, fmt.Errorf("%w %q", ErrInvalidLastWriteTime, value)
+	}
+
+	dateFields := strings.Split(date, "-")
+	clockFields := strings.Split(clock, ":")
+	if len(dateFields) != 3 || len(clockFields) != 3 {
+		return Timestamp{}, fmt.Errorf("%w %q", ErrInvalidLastWriteTime, value)
+	}
+
+	fields := [6]uint16{}
+	for i, field := range append(dateFields, clockFields...) {
+		v, err := strconv.ParseUint(field, 10, 16)
+		if err != nil {
+			return Timestamp{}, fmt.Errorf("%w %q", ErrInvalidLastWriteTime, value)
+		}
+		fields[i] = uint16(v)
+	}
+
+	return Timestamp{
+		Year:   fields[0],
+		Month:  fields[1],
+		Day:    fields[2],
+		Hour:   fields[3],
+		Minute: fields[4],
+		Second: fields[5],
+	}, nil
+}
+
+func (self Timestamp) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", self.Year, self.Month, self.Day, self.Hour, self.Minute, self.Second)
+}
+
+func (self Timestamp) IsZero() bool {
+	return self == Timestamp{}
+}
+
+// Time converts to a time.Time in UTC, reporting false when the fields do
+// not form a real date.
+func (self Timestamp) Time() (time.Time, bool) {
+	t := time.Date(
+		int(self.Year),
+		time.Month(self.Month),
+		int(self.Day),
+		int(self.Hour),
+		int(self.Minute),
+		int(self.Second),
+		0,
+		time.UTC,
+	)
+
+	return t, NewTimestamp(t) == self
+}
+
+func (self Timestamp) IsValid() bool {
+	_, valid := self.Time()
+	return valid
+}
+
+func (self Timestamp) MarshalText() ([]byte, error) {
+	return []byte(self.String()), nil
+}
+
+// UnmarshalText accepts an empty string as the zero timestamp, which older
+// metadata files use for null entries.
+func (self *Timestamp) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*self = Timestamp{}
+		return nil
+	}
+
+	timestamp, err := ParseTimestamp(string(text))
+	if err != nil {
+		return err
+	}
+
+	*self = timestamp
+
+	return nil
+}
+
+func (self Timestamp) write(w io.Writer) error {
+	return binary.Write(w, binary.LittleEndian, [6]uint16{self.Year, self.Month, self.Day, self.Hour, self.Minute, self.Second})
+}
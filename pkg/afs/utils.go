@@ -1,33 +1,49 @@
 package afs
 
 import (
+	"fmt"
+	"io"
+	"io/fs"
 	"path"
 	"strings"
-	"unicode"
 )
 
 func _IsAttributeInfoValid(attributesOffset, attributesSize, afsFileSize, entryTotal, dataBlockEndOffset uint32) bool {
+	return _AttributeInfoProblem(attributesOffset, attributesSize, afsFileSize, entryTotal, dataBlockEndOffset) == ""
+}
+
+// _AttributeInfoProblem tells why an attribute info does not point to an
+// attribute table, or returns an empty string when it does.
+func _AttributeInfoProblem(attributesOffset, attributesSize, afsFileSize, entryTotal, dataBlockEndOffset uint32) string {
 	if attributesOffset == 0 {
-		return false
+		return "offset is zero"
 	}
 	if attributesSize == 0 {
-		return false
+		return "size is zero"
 	}
 
-	if attributesSize > afsFileSize-dataBlockEndOffset {
-		return false
+	start := uint64(attributesOffset)
+	end := start + uint64(attributesSize)
+
+	if end > uint64(afsFileSize) {
+		return fmt.Sprintf("table 0x%X-0x%X extends past end of file 0x%X", start, end, afsFileSize)
 	}
-	if attributesSize < entryTotal*AttributeElementSize {
-		return false
+	if required := uint64(entryTotal) * uint64(AttributeElementSize); uint64(attributesSize) < required {
+		return fmt.Sprintf("size 0x%X is smaller than 0x%X for %d entries", attributesSize, required, entryTotal)
 	}
 	if attributesOffset < dataBlockEndOffset {
-		return false
-	}
-	if attributesOffset > afsFileSize-attributesSize {
-		return false
+		return fmt.Sprintf("table at 0x%X starts before end of entry data 0x%X", start, dataBlockEndOffset)
 	}
 
-	return true
+	return ""
+}
+
+func _CopyEntry(dst io.Writer, src io.Reader, entry *Entry) error {
+	written, err := io.CopyN(dst, src, int64(entry.Size))
+	if err == io.EOF {
+		return fmt.Errorf("Short read, got %d of %d bytes: %w", written, entry.Size, io.ErrUnexpectedEOF)
+	}
+	return err
 }
 
 func _Pad(value uint32, alignment uint32) uint32 {
@@ -39,15 +55,54 @@ func _Pad(value uint32, alignment uint32) uint32 {
 	}
 }
 
-func _StringFromBytes(b []byte) string {
-	str := string(b)
-	return strings.Map(func(r rune) rune {
-		if unicode.IsPrint(r) {
-			return r
+// UniqueNames gives every entry a name usable as a file name, the name used
+// when unpacking and matching overlay files. Empty names and names that are
+// not a single path element become the zero padded index, and duplicates are
+// renamed to name_1.ext, name_2.ext and so on, skipping names that any entry
+// already has.
+func UniqueNames(entries []*Entry) []string {
+	result := make([]string, 0, len(entries))
+	used := map[string]bool{}
+
+	for i, entry := range entries {
+		name := entry.Name
+		if name == "" || name == "." || strings.ContainsAny(name, "/\\") || !fs.ValidPath(name) {
+			name = fmt.Sprintf("%08d", i)
+		}
+
+		result = append(result, name)
+		used[name] = true
+	}
+
+	taken := map[string]bool{}
+	counts := map[string]int{}
+
+	for i, name := range result {
+		if !taken[name] {
+			taken[name] = true
+			continue
+		}
+
+		unique := name
+		for used[unique] {
+			counts[name] += 1
+			unique = fmt.Sprintf("%s_%d%s", _BasenameWithoutExtension(name), counts[name], _Extension(name))
 		}
-		return -1
-	}, str)
 
+		used[unique] = true
+		taken[unique] = true
+		result[i] = unique
+	}
+
+	return result
+}
+
+func _NameIndices(names []string) map[string]int {
+	indices := make(map[string]int, len(names))
+	for i, name := range names {
+		indices[name] = i
+	}
+	return indices
 }
 
 func _Basename(p string) string {
@@ -0,0 +1,214 @@
+package afs
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (self Severity) String() string {
+	switch self {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("severity(%d)", int(self))
+	}
+}
+
+func (self Severity) MarshalText() ([]byte, error) {
+	return []byte(self.String()), nil
+}
+
+type Issue struct {
+	Severity Severity `json:"severity"`
+	Entry    int      `json:"entry"`
+	Message  string   `json:"message"`
+}
+
+type Report struct {
+	Issues []*Issue `json:"issues"`
+}
+
+func (self *Report) add(severity Severity, entry int, format string, args ...any) {
+	self.Issues = append(
+		self.Issues,
+		&Issue{
+			Severity: severity,
+			Entry:    entry,
+			Message:  fmt.Sprintf(format, args...),
+		},
+	)
+}
+
+func (self *Report) Count(severity Severity) int {
+	count := 0
+	for _, issue := range self.Issues {
+		if issue.Severity == severity {
+			count += 1
+		}
+	}
+	return count
+}
+
+func (self *Report) HasErrors() bool {
+	return self.Count(SeverityError) > 0
+}
+
+type _Region struct {
+	entry int
+	start uint64
+	end   uint64
+}
+
+func Verify(afs *Afs) *Report {
+	report := &Report{
+		Issues: []*Issue{},
+	}
+
+	entryTotal := uint32(len(afs.Entries))
+	if afs.EntryTotal != entryTotal {
+		report.add(SeverityError, -1, "Entry total %d does not match entry count %d", afs.EntryTotal, entryTotal)
+	}
+
+	tableEnd := uint64(HeaderSize + (entryTotal * EntryInfoElementSize))
+	if afs.AttributesInfo == AttributesInfoInfoAtStart {
+		tableEnd += uint64(AttributeInfoSize)
+	}
+	fileSize := uint64(afs.size)
+	parsed := afs.size > 0
+
+	regions := []*_Region{}
+	names := map[string]int{}
+
+	for i, entry := range afs.Entries {
+		if entry.IsNull {
+			if entry.Size != 0 {
+				report.add(SeverityWarning, i, "Null entry has non-zero size %d", entry.Size)
+			}
+			continue
+		}
+
+		start := uint64(entry.Offset)
+		end := start + uint64(entry.Size)
+
+		if parsed {
+			if start < tableEnd {
+				report.add(SeverityError, i, "Data at 0x%X is inside the header or entry table (ends at 0x%X)", start, tableEnd)
+			}
+
+			if start > fileSize {
+				report.add(SeverityError, i, "Offset 0x%X is past end of file 0x%X", start, fileSize)
+			} else if end > fileSize {
+				report.add(SeverityError, i, "Data 0x%X-0x%X extends past end of file 0x%X", start, end, fileSize)
+			}
+
+			if afs.DataAlignment != 0 && entry.Offset%afs.DataAlignment != 0 {
+				report.add(SeverityInfo, i, "Offset 0x%X is not aligned to 0x%X", entry.Offset, afs.DataAlignment)
+			}
+
+			if entry.Size > 0 {
+				regions = append(regions, &_Region{entry: i, start: start, end: end})
+			}
+		}
+
+		if afs.AttributesInfo == AttributesInfoNoAttribute {
+			continue
+		}
+
+		if _, err := afs.NameEncoding.nameField(entry); errors.Is(err, ErrEntryNameTooLong) {
+			report.add(SeverityError, i, "Name %s is longer than %d bytes as %s", entry.Name, MaxEntryNameLength, afs.NameEncoding)
+		} else if err != nil {
+			report.add(SeverityError, i, "Name %s cannot be written as %s", entry.Name, afs.NameEncoding)
+		}
+
+		if !entry.LastWriteTime.IsValid() {
+			report.add(SeverityWarning, i, "Last write time %s is not a valid date", entry.LastWriteTime)
+		}
+
+		if first, found := names[entry.Name]; found {
+			report.add(SeverityInfo, i, "Name %s is also used by entry %d", entry.Name, first)
+		} else {
+			names[entry.Name] = i
+		}
+	}
+
+	slices.SortFunc(regions, func(a, b *_Region) int {
+		if a.start != b.start {
+			return cmp.Compare(a.start, b.start)
+		}
+		return cmp.Compare(a.entry, b.entry)
+	})
+
+	var last *_Region
+	for _, region := range regions {
+		if last != nil && last.end > region.start {
+			report.add(SeverityError, region.entry, "Data overlaps entry %d", last.entry)
+		}
+
+		if last == nil || region.end > last.end {
+			last = region
+		}
+	}
+
+	if parsed && afs.AttributesInfo != AttributesInfoNoAttribute {
+		start := uint64(afs.attributesOffset)
+		end := start + uint64(afs.attributesSize)
+
+		if afs.attributesSize < entryTotal*AttributeElementSize {
+			report.add(SeverityError, -1, "Attribute table size 0x%X is smaller than 0x%X", afs.attributesSize, entryTotal*AttributeElementSize)
+		}
+
+		if start < tableEnd {
+			report.add(SeverityError, -1, "Attribute table at 0x%X is inside the header or entry table", start)
+		}
+
+		if end > fileSize {
+			report.add(SeverityError, -1, "Attribute table 0x%X-0x%X extends past end of file 0x%X", start, end, fileSize)
+		}
+
+		for _, region := range regions {
+			if region.start < end && start < region.end {
+				report.add(SeverityError, region.entry, "Data overlaps the attribute table at 0x%X-0x%X", start, end)
+			}
+		}
+	} else if parsed {
+		dataStart := fileSize
+		dataEnd := uint64(0)
+		for _, region := range regions {
+			dataStart = min(dataStart, region.start)
+			dataEnd = max(dataEnd, region.end)
+		}
+
+		fill := uint32(afs.PaddingFill) * 0x01010101
+		for _, candidate := range afs.attributesInfoCandidates {
+			if (candidate.offset == 0 && candidate.size == 0) || (candidate.offset == fill && candidate.size == fill) {
+				continue
+			}
+
+			position := uint64(candidate.position)
+			if position < uint64(HeaderSize+(entryTotal*EntryInfoElementSize)) || position+uint64(AttributeInfoSize) > dataStart {
+				continue
+			}
+
+			problem := _AttributeInfoProblem(candidate.offset, candidate.size, uint32(fileSize), afs.EntryTotal, uint32(dataEnd))
+			if problem != "" {
+				report.add(SeverityError, -1, "Attribute info at 0x%X does not point to an attribute table, %s", position, problem)
+			}
+		}
+	}
+
+	return report
+}
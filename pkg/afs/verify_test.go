@@ -0,0 +1,163 @@
+package afs
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVerifyCleanArchives(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(a *Afs)
+		entries []_TestEntry
+	}{
+		{
+			name:    "default",
+			entries: _TestEntries(),
+		},
+		{
+			name: "small alignment",
+			setup: func(a *Afs) {
+				a.DataAlignment = 0x10
+				a.EndAlignment = 4
+				a.PaddingFill = 0xFF
+			},
+			entries: _TestEntries(),
+		},
+		{
+			name: "attribute info at end",
+			setup: func(a *Afs) {
+				a.AttributesInfo = AttributesInfoInfoAtEnd
+			},
+			entries: _TestEntries(),
+		},
+		{
+			name: "no attributes",
+			setup: func(a *Afs) {
+				a.AttributesInfo = AttributesInfoNoAttribute
+			},
+			entries: _TestEntries(),
+		},
+		{
+			name: "shift-jis name of 30 bytes",
+			setup: func(a *Afs) {
+				a.NameEncoding = NameEncodingShiftJIS
+			},
+			entries: []_TestEntry{
+				{name: "ゲームデータファイル一覧表です", data: _TestData(1, 10)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := _OpenTestArchive(t, _BuildTestArchive(t, test.setup, test.entries))
+
+			for _, issue := range Verify(a).Issues {
+				t.Errorf("%s entry %d: %s", issue.Severity, issue.Entry, issue.Message)
+			}
+		})
+	}
+}
+
+func TestVerifyCorruptArchives(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(a *Afs, data []byte) []byte
+		message string
+	}{
+		{
+			name: "attribute table past end of file",
+			corrupt: func(a *Afs, data []byte) []byte {
+				binary.LittleEndian.PutUint32(data[HeaderSize+(a.EntryTotal*EntryInfoElementSize):], uint32(len(data))+0x1000)
+				return data
+			},
+			message: "Attribute info at 0x30",
+		},
+		{
+			name: "truncated attribute table",
+			corrupt: func(a *Afs, data []byte) []byte {
+				return data[:a.attributesOffset+(a.attributesSize/2)]
+			},
+			message: "extends past end of file",
+		},
+		{
+			name: "attribute table too small",
+			corrupt: func(a *Afs, data []byte) []byte {
+				binary.LittleEndian.PutUint32(data[HeaderSize+(a.EntryTotal*EntryInfoElementSize)+4:], AttributeElementSize)
+				return data
+			},
+			message: "is smaller than",
+		},
+		{
+			name: "entry total too large",
+			corrupt: func(a *Afs, data []byte) []byte {
+				binary.LittleEndian.PutUint32(data[4:], 0x100000)
+				return data
+			},
+			message: "Entry total 1048576 does not match",
+		},
+		{
+			name: "overlapping data",
+			corrupt: func(a *Afs, data []byte) []byte {
+				binary.LittleEndian.PutUint32(data[HeaderSize+(3*EntryInfoElementSize):], a.Entries[0].Offset)
+				return data
+			},
+			message: "Data overlaps entry",
+		},
+		{
+			name: "data past end of file",
+			corrupt: func(a *Afs, data []byte) []byte {
+				binary.LittleEndian.PutUint32(data[HeaderSize+(4*EntryInfoElementSize)+4:], uint32(len(data)))
+				return data
+			},
+			message: "extends past end of file",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := _BuildTestArchive(t, nil, _TestEntries())
+			a := _OpenTestArchive(t, path)
+
+			if err := os.WriteFile(path, test.corrupt(a, _ReadTestFile(t, path)), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			corrupt, err := OpenFileLenient(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer corrupt.Close()
+
+			report := Verify(corrupt)
+			for _, issue := range report.Issues {
+				if issue.Severity == SeverityError && strings.Contains(issue.Message, test.message) {
+					return
+				}
+			}
+
+			t.Errorf("no error containing %q in %d issues", test.message, len(report.Issues))
+			for _, issue := range report.Issues {
+				t.Logf("%s entry %d: %s", issue.Severity, issue.Entry, issue.Message)
+			}
+		})
+	}
+}
+
+func TestOpenFileTruncatedEntryTable(t *testing.T) {
+	path := _BuildTestArchive(t, nil, _TestEntries())
+
+	data := _ReadTestFile(t, path)
+	binary.LittleEndian.PutUint32(data[4:], 0x100000)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenFile(path); !errors.Is(err, ErrTruncated) {
+		t.Errorf("got %v, want %v", err, ErrTruncated)
+	}
+}
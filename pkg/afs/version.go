@@ -1,8 +1,14 @@
 package afs
 
+import "fmt"
+
 type Version uint8
 
 const (
 	Version00 Version = 0x00
 	Version20 Version = 0x20
 )
+
+func (self Version) String() string {
+	return fmt.Sprintf("0x%02X", uint8(self))
+}
@@ -0,0 +1,334 @@
+package afs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+type Writer struct {
+	Version             Version
+	AttributesInfo      AttributesInfo
+	EntryBlockAlignment uint32
+	DataAlignment       uint32
+	EndAlignment        uint32
+	PaddingFill         byte
+	NameEncoding        NameEncoding
+
+	writer     io.WriteSeeker
+	entryTotal uint32
+	entries    []*Entry
+	position   uint32
+	started    bool
+	closed     bool
+	err        error
+}
+
+func NewWriter(writer io.WriteSeeker, entryTotal uint32) *Writer {
+	return &Writer{
+		Version:             Version00,
+		AttributesInfo:      AttributesInfoInfoAtStart,
+		EntryBlockAlignment: 0x800,
+		DataAlignment:       AlignmentSize,
+		EndAlignment:        AlignmentSize,
+		PaddingFill:         0,
+		writer:              writer,
+		entryTotal:          entryTotal,
+		entries:             []*Entry{},
+	}
+}
+
+func (self *Writer) AddEntry(name string, modTime time.Time, size int64, reader io.Reader) error {
+	if size < 0 || size > math.MaxUint32 {
+		return fmt.Errorf("%w, entry %s has %d bytes", ErrEntrySizeOutOfRange, name, size)
+	}
+
+	return self.writeEntry(
+		&Entry{
+			Name:          name,
+			Size:          uint32(size),
+			LastWriteTime: NewTimestamp(modTime),
+			CustomData:    uint32(size),
+		},
+		reader,
+	)
+}
+
+func (self *Writer) AddNullEntry() error {
+	return self.writeEntry(
+		&Entry{
+			IsNull: true,
+		},
+		nil,
+	)
+}
+
+func (self *Writer) Close() error {
+	if self.err != nil {
+		return self.err
+	}
+
+	if self.closed {
+		return nil
+	}
+
+	if uint32(len(self.entries)) != self.entryTotal {
+		return fmt.Errorf("%w, expected %d entries, got %d", ErrEntryTotalMismatch, self.entryTotal, len(self.entries))
+	}
+
+	if err := self.start(); err != nil {
+		return err
+	}
+
+	attributesOffset := self.position
+	end := self.position
+
+	if self.AttributesInfo != AttributesInfoNoAttribute {
+		if err := _WriteAttributes(self.writer, self.entries, self.NameEncoding); err != nil {
+			return err
+		}
+		end += self.entryTotal * AttributeElementSize
+	}
+
+	if err := _WritePadding(self.writer, self.PaddingFill, _Pad(end, self.EndAlignment)-end); err != nil {
+		return err
+	}
+
+	header, err := _EncodeHeader(self.Version, self.AttributesInfo, self.EntryBlockAlignment, self.PaddingFill, self.entries, attributesOffset)
+	if err != nil {
+		return err
+	}
+
+	if _, err := self.writer.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if _, err := self.writer.Write(header); err != nil {
+		return err
+	}
+
+	if _, err := self.writer.Seek(int64(_Pad(end, self.EndAlignment)), io.SeekStart); err != nil {
+		return err
+	}
+
+	self.closed = true
+
+	return nil
+}
+
+func (self *Writer) start() error {
+	if self.started {
+		return nil
+	}
+
+	if self.EntryBlockAlignment == 0 {
+		return fmt.Errorf("%w, entry block alignment is 0", ErrInvalidAlignment)
+	}
+
+	if err := _ValidateAlignment(self.DataAlignment, self.EndAlignment); err != nil {
+		return err
+	}
+
+	if _, err := self.writer.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	self.position = _FirstEntryOffset(self.entryTotal, self.EntryBlockAlignment)
+	if err := _WritePadding(self.writer, self.PaddingFill, self.position); err != nil {
+		self.err = err
+		return err
+	}
+
+	self.started = true
+
+	return nil
+}
+
+func (self *Writer) writeEntry(entry *Entry, reader io.Reader) error {
+	if self.err != nil {
+		return self.err
+	}
+
+	if self.closed {
+		return ErrWriterClosed
+	}
+
+	index := len(self.entries)
+
+	if uint32(index) >= self.entryTotal {
+		return fmt.Errorf("%w, expected %d entries", ErrEntryTotalMismatch, self.entryTotal)
+	}
+
+	if err := _ValidateEntry(entry, self.AttributesInfo, self.NameEncoding); err != nil {
+		return _EntryError(index, entry, err)
+	}
+
+	if err := self.start(); err != nil {
+		return err
+	}
+
+	if entry.IsNull {
+		entry.Offset = 0
+		entry.Size = 0
+		self.entries = append(self.entries, entry)
+		return nil
+	}
+
+	if uint64(self.position)+uint64(entry.Size) > math.MaxUint32 {
+		return _EntryError(index, entry, ErrArchiveTooLarge)
+	}
+
+	entry.Offset = self.position
+
+	if err := _CopyEntry(self.writer, reader, entry); err != nil {
+		self.err = _EntryError(index, entry, err)
+		return self.err
+	}
+
+	end := self.position + entry.Size
+	self.position = _Pad(end, self.DataAlignment)
+
+	if err := _WritePadding(self.writer, self.PaddingFill, self.position-end); err != nil {
+		self.err = err
+		return err
+	}
+
+	self.entries = append(self.entries, entry)
+
+	return nil
+}
+
+func _ValidateEntry(entry *Entry, attributesInfo AttributesInfo, nameEncoding NameEncoding) error {
+	if entry.IsNull || attributesInfo == AttributesInfoNoAttribute {
+		return nil
+	}
+
+	if _, err := nameEncoding.nameField(entry); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func _FirstEntryOffset(entryTotal uint32, entryBlockAlignment uint32) uint32 {
+	return _Pad(
+		HeaderSize+(EntryInfoElementSize*entryTotal)+AttributeInfoSize,
+		entryBlockAlignment,
+	)
+}
+
+func _EncodeHeader(
+	version Version,
+	attributesInfo AttributesInfo,
+	entryBlockAlignment uint32,
+	paddingFill byte,
+	entries []*Entry,
+	attributesOffset uint32,
+) ([]byte, error) {
+	entryTotal := uint32(len(entries))
+	firstEntryOffset := _FirstEntryOffset(entryTotal, entryBlockAlignment)
+
+	buf := bytes.NewBuffer(make([]byte, 0, firstEntryOffset))
+	if err := _WriteHeader(buf, version, entries); err != nil {
+		return nil, err
+	}
+
+	result := bytes.Repeat([]byte{paddingFill}, int(firstEntryOffset))
+	copy(result, buf.Bytes())
+
+	if attributesInfo != AttributesInfoNoAttribute {
+		attributesInfoPosition := HeaderSize + (entryTotal * EntryInfoElementSize)
+		if attributesInfo == AttributesInfoInfoAtEnd {
+			attributesInfoPosition = firstEntryOffset - AttributeInfoSize
+		}
+
+		binary.LittleEndian.PutUint32(result[attributesInfoPosition:], attributesOffset)
+		binary.LittleEndian.PutUint32(result[attributesInfoPosition+4:], entryTotal*AttributeElementSize)
+	}
+
+	return result, nil
+}
+
+func _WriteHeader(w io.Writer, version Version, entries []*Entry) error {
+	if err := binary.Write(w, binary.LittleEndian, Signature|(uint32(version)<<(3*8))); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(entries))); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsNull {
+			if err := binary.Write(w, binary.LittleEndian, uint32(0)); err != nil {
+				return err
+			}
+			if err := binary.Write(w, binary.LittleEndian, uint32(0)); err != nil {
+				return err
+			}
+		} else {
+			if err := binary.Write(w, binary.LittleEndian, entry.Offset); err != nil {
+				return err
+			}
+			if err := binary.Write(w, binary.LittleEndian, entry.Size); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func _WriteAttributes(w io.Writer, entries []*Entry, nameEncoding NameEncoding) error {
+	for _, entry := range entries {
+		if entry.IsNull {
+			if err := _WriteZeros(w, AttributeElementSize); err != nil {
+				return err
+			}
+			continue
+		}
+
+		field, err := nameEncoding.nameField(entry)
+		if err != nil {
+			return err
+		}
+
+		name := make([]byte, MaxEntryNameLength)
+		copy(name, field)
+
+		if _, err := w.Write(name); err != nil {
+			return err
+		}
+
+		if err := entry.LastWriteTime.write(w); err != nil {
+			return err
+		}
+
+		if err := binary.Write(w, binary.LittleEndian, entry.CustomData); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func _WritePadding(w io.Writer, fill byte, size uint32) error {
+	if size == 0 {
+		return nil
+	}
+
+	_, err := w.Write(bytes.Repeat([]byte{fill}, int(size)))
+	return err
+}
+
+func _WriteZeros(w io.Writer, size uint32) error {
+	if size == 0 {
+		return nil
+	}
+
+	_, err := w.Write(make([]byte, size))
+	return err
+}